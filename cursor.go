@@ -0,0 +1,428 @@
+//go:build js && wasm
+
+package indexeddb
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"syscall/js"
+)
+
+var (
+	ErrCursorExhausted = errors.New("cursor has no more records")
+	ErrReadOnly        = errors.New("transaction is read only")
+
+	// returned from an `Each` callback to stop early without an error.
+	StopIteration = errors.New("stop iteration")
+)
+
+type Direction int
+
+const (
+	Next Direction = iota
+	// skip records with the same key as the previous record, only useful for an index.
+	NextUnique
+	Prev
+	PrevUnique
+)
+
+var directions = [...]string{
+	Next:       "next",
+	NextUnique: "nextunique",
+	Prev:       "prev",
+	PrevUnique: "prevunique",
+}
+
+func (d Direction) Verify() bool {
+	return d >= Next && d <= PrevUnique
+}
+
+func (d Direction) String() string {
+	return directions[int(d)]
+}
+
+// a cursor iterates over the records of a store or index.
+// a cursor is reused for every record, check `Valid` after moving it.
+//
+// https://developer.mozilla.org/en-US/docs/Web/API/IDBCursor.
+type Cursor struct {
+	// the request that fires every time the cursor moves.
+	req js.Value
+
+	// the current `IDBCursor`, null when iterated past the end.
+	value js.Value
+
+	// opened with `openKeyCursor`, so records have no value.
+	keyOnly bool
+}
+
+// open a cursor over the records within the range, a nil range matches every key.
+func (s *Store) OpenCursor(rng *KeyRange, dir Direction) (*Cursor, error) {
+	err := s.check()
+	if err != nil {
+		return nil, err
+	}
+
+	return openCursor(s.value, "openCursor", rng, dir)
+}
+
+// open a cursor over the keys within the range, without the values.
+// `Value` of the cursor always returns undefined.
+func (s *Store) OpenKeyCursor(rng *KeyRange, dir Direction) (*Cursor, error) {
+	err := s.check()
+	if err != nil {
+		return nil, err
+	}
+
+	return openCursor(s.value, "openKeyCursor", rng, dir)
+}
+
+// open a cursor over the records within the range of the index, ordered by the index key.
+func (i *Index) OpenCursor(rng *KeyRange, dir Direction) (*Cursor, error) {
+	return openCursor(i.value, "openCursor", rng, dir)
+}
+
+// iterate over the keys and values of the records within the range, a nil range matches every key.
+// iteration stops early on an error, which is returned by the error func afterwards.
+func (s *Store) All(rng *KeyRange, dir Direction) (iter.Seq2[js.Value, js.Value], func() error) {
+	var err error
+
+	seq := func(yield func(js.Value, js.Value) bool) {
+		err = nil
+
+		var c *Cursor
+
+		c, err = s.OpenCursor(rng, dir)
+		if err != nil {
+			return
+		}
+
+		for c.Valid() {
+			if !yield(c.Key(), c.Value()) {
+				return
+			}
+
+			err = c.Continue(nil)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	return seq, func() error {
+		return err
+	}
+}
+
+// call fn for every record within the range, a nil range matches every key.
+// iteration stops at the first error fn returns, which is returned unless it's `StopIteration`.
+func (s *Store) Each(rng *KeyRange, dir Direction, fn func(key, value js.Value) error) error {
+	c, err := s.OpenCursor(rng, dir)
+	if err != nil {
+		return err
+	}
+
+	for c.Valid() {
+		err = fn(c.Key(), c.Value())
+		if errors.Is(err, StopIteration) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		err = c.Continue(nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// get the value of the record with the lowest key.
+func (s *Store) First() (*js.Value, error) {
+	return s.edge(Next)
+}
+
+// get the value of the record with the highest key.
+func (s *Store) Last() (*js.Value, error) {
+	return s.edge(Prev)
+}
+
+// get the value of the first record in the direction.
+func (s *Store) edge(dir Direction) (*js.Value, error) {
+	c, err := s.OpenCursor(nil, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// check if the store is empty.
+	if !c.Valid() {
+		return nil, ErrValueNotFound
+	}
+
+	val := c.Value()
+
+	return &val, nil
+}
+
+// get a page of values, skipping offset records and returning up to limit values.
+func (s *Store) Page(rng *KeyRange, dir Direction, offset, limit int) ([]js.Value, error) {
+	c, err := s.OpenCursor(rng, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// advancing by 0 is invalid.
+	if offset > 0 && c.Valid() {
+		err = c.Advance(offset)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var vals []js.Value
+
+	for c.Valid() && len(vals) < limit {
+		vals = append(vals, c.Value())
+
+		// don't move past the last value we need.
+		if len(vals) == limit {
+			break
+		}
+
+		err = c.Continue(nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return vals, nil
+}
+
+// open a cursor on a store or index with `openCursor` or `openKeyCursor`.
+func openCursor(v js.Value, method string, rng *KeyRange, dir Direction) (*Cursor, error) {
+	// ensure the direction is valid.
+	if !dir.Verify() {
+		return nil, errors.New("direction must be next, next unique, prev or prev unique")
+	}
+
+	if debugging() {
+		Logger.Debug("cursor open", "source", v.Get("name").String(), "method", method, "direction", dir)
+	}
+
+	req, err := call(v, method, rng.query(), dir.String())
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Cursor{
+		req:     req,
+		keyOnly: method == "openKeyCursor",
+	}
+
+	// wait for the first record.
+	err = c.await()
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// wait for the cursor to move.
+func (c *Cursor) await() error {
+	err := await(c.req, nil)
+	if err != nil {
+		return err
+	}
+
+	c.value = c.req.Get("result")
+
+	return nil
+}
+
+// valid reports whether the cursor is positioned on a record.
+func (c *Cursor) Valid() bool {
+	return !c.value.IsNull() && !c.value.IsUndefined()
+}
+
+// the key of the current record, for an index this is the index key.
+func (c *Cursor) Key() js.Value {
+	if !c.Valid() {
+		return js.Undefined()
+	}
+
+	return c.value.Get("key")
+}
+
+// the primary key of the current record, for a store this is the same as `Key`.
+func (c *Cursor) PrimaryKey() js.Value {
+	if !c.Valid() {
+		return js.Undefined()
+	}
+
+	return c.value.Get("primaryKey")
+}
+
+// the key of the current record as an int, 0 if it's not a number.
+func (c *Cursor) KeyInt() int {
+	return keyInt(c.Key())
+}
+
+// the key of the current record as a string, empty if it's not a string.
+func (c *Cursor) KeyString() string {
+	return keyString(c.Key())
+}
+
+// the primary key of the current record as an int, 0 if it's not a number.
+func (c *Cursor) PrimaryKeyInt() int {
+	return keyInt(c.PrimaryKey())
+}
+
+// the primary key of the current record as a string, empty if it's not a string.
+func (c *Cursor) PrimaryKeyString() string {
+	return keyString(c.PrimaryKey())
+}
+
+func keyInt(v js.Value) int {
+	if !is(v, js.TypeNumber) {
+		return 0
+	}
+
+	return v.Int()
+}
+
+func keyString(v js.Value) string {
+	if !is(v, js.TypeString) {
+		return ""
+	}
+
+	return v.String()
+}
+
+// the value of the current record.
+func (c *Cursor) Value() js.Value {
+	if !c.Valid() || c.keyOnly {
+		return js.Undefined()
+	}
+
+	return c.value.Get("value")
+}
+
+// move the cursor to the next record, or to the first record at or after the key if it's not nil.
+// a key before the current position returns `ErrData`.
+func (c *Cursor) Continue(key any) error {
+	if !c.Valid() {
+		return ErrCursorExhausted
+	}
+
+	if debugging() {
+		Logger.Debug("cursor continue", "key", c.Key(), "to", key)
+	}
+
+	// the key should be undefined to move to the next record.
+	k := js.Undefined()
+
+	if key != nil {
+		var err error
+
+		k, err = toKey(key)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := call(c.value, "continue", k)
+	if err != nil {
+		return err
+	}
+
+	// wait for the cursor to move.
+	return c.await()
+}
+
+// move the cursor forward by n records, n must be at least 1.
+func (c *Cursor) Advance(n int) error {
+	if !c.Valid() {
+		return ErrCursorExhausted
+	}
+
+	// indexeddb throws a `TypeError` for these.
+	if n < 1 {
+		return fmt.Errorf("cannot advance by %d, must be at least 1", n)
+	}
+
+	if debugging() {
+		Logger.Debug("cursor advance", "key", c.Key(), "count", n)
+	}
+
+	_, err := call(c.value, "advance", n)
+	if err != nil {
+		return err
+	}
+
+	// wait for the cursor to move.
+	return c.await()
+}
+
+// replace the value of the current record.
+func (c *Cursor) Update(value any) error {
+	err := c.writable()
+	if err != nil {
+		return err
+	}
+
+	val, err := Codec.Marshal(value)
+	if err != nil {
+		return errors.Join(ErrValueInvalid, err)
+	}
+
+	if debugging() {
+		Logger.Debug("cursor update", "key", c.Key(), "value", value)
+	}
+
+	req, err := call(c.value, "update", val)
+	if err != nil {
+		return err
+	}
+
+	// wait for the request to complete.
+	return await(req, nil)
+}
+
+// delete the current record, the cursor stays in place.
+func (c *Cursor) Delete() error {
+	err := c.writable()
+	if err != nil {
+		return err
+	}
+
+	if debugging() {
+		Logger.Debug("cursor delete", "key", c.Key())
+	}
+
+	req, err := call(c.value, "delete")
+	if err != nil {
+		return err
+	}
+
+	// wait for the request to complete.
+	return await(req, nil)
+}
+
+// ensure the cursor is on a record and within a transaction that can write.
+func (c *Cursor) writable() error {
+	if !c.Valid() {
+		return ErrCursorExhausted
+	}
+
+	if c.req.Get("transaction").Get("mode").String() == ReadMode.String() {
+		return ErrReadOnly
+	}
+
+	return nil
+}
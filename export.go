@@ -0,0 +1,209 @@
+//go:build js && wasm
+
+package indexeddb
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"syscall/js"
+)
+
+// export every record in the store as a JSON array of `{"key": ..., "value": ...}` objects.
+// keys and values that wouldn't import as the same type, such as dates, binary and bigints, return `ErrInvalidType`.
+func (s *Store) Export() ([]byte, error) {
+	cur, err := s.OpenCursor(nil, Next)
+	if err != nil {
+		return nil, err
+	}
+
+	records := Array.New()
+
+	for i := 0; cur.Valid(); i++ {
+		err = exportable(cur.PrimaryKey())
+		if err != nil {
+			return nil, fmt.Errorf("record #%d key: %w", i, err)
+		}
+
+		err = exportable(cur.Value())
+		if err != nil {
+			return nil, fmt.Errorf("record #%d value: %w", i, err)
+		}
+
+		record := Object.New()
+		record.Set("key", cur.PrimaryKey())
+		record.Set("value", cur.Value())
+
+		records.Call("push", record)
+
+		err = cur.Continue(nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	str, err := call(JSON, "stringify", records)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(str.String()), nil
+}
+
+// ensure a value is the same type after a round trip through JSON.
+func exportable(v js.Value) error {
+	// `JSON.stringify` throws for a bigint.
+	if isBigInt(v) {
+		return errors.Join(ErrInvalidType, errors.New("bigint can't be exported"))
+	}
+
+	switch v.Type() {
+	case js.TypeUndefined, js.TypeNull, js.TypeBoolean, js.TypeString:
+		return nil
+
+	// NaN and Infinity become null.
+	case js.TypeNumber:
+		if math.IsNaN(v.Float()) || math.IsInf(v.Float(), 0) {
+			return errors.Join(ErrInvalidType, fmt.Errorf("%v can't be exported", v.Float()))
+		}
+
+		return nil
+
+	case js.TypeObject:
+		if Array.Call("isArray", v).Bool() {
+			for i, el := range ToSlice(v) {
+				err := exportable(el)
+				if err != nil {
+					return fmt.Errorf("index %d: %w", i, err)
+				}
+			}
+
+			return nil
+		}
+
+		// only plain objects keep their type, dates become strings and binary becomes an object of bytes.
+		proto := Object.Call("getPrototypeOf", v)
+
+		if !proto.IsNull() && !proto.Equal(Object.Get("prototype")) {
+			return errors.Join(ErrInvalidType, fmt.Errorf("%s can't be exported", proto.Get("constructor").Get("name").String()))
+		}
+
+		for _, prop := range StringList(Object.Call("keys", v)) {
+			err := exportable(v.Get(prop))
+			if err != nil {
+				return fmt.Errorf("property %s: %w", prop, err)
+			}
+		}
+
+		return nil
+
+	default:
+		return errors.Join(ErrInvalidType, fmt.Errorf("%s can't be exported", v.Type()))
+	}
+}
+
+// import records from a JSON array of `{"key": ..., "value": ...}` objects, such as from `Export`.
+// existing records with the same key are replaced.
+func (s *Store) Import(data []byte) error {
+	records, err := call(JSON, "parse", string(data))
+	if err != nil {
+		return err
+	}
+
+	if !Array.Call("isArray", records).Bool() {
+		return errors.New("expected an array of records")
+	}
+
+	// the key is part of the value for a store with in-line keys.
+	inline := !s.value.Get("keyPath").IsNull()
+
+	list := ToSlice(records)
+
+	// validate every record first, so a malformed record doesn't leave the records before it to commit.
+	for i, record := range list {
+		if record.Type() != js.TypeObject || record.IsNull() {
+			return fmt.Errorf("record %d is not an object", i)
+		}
+
+		if !inline && record.Get("key").IsUndefined() {
+			return fmt.Errorf("record %d has no key", i)
+		}
+	}
+
+	b := s.Batch()
+
+	for i, record := range list {
+		var key any
+
+		if !inline {
+			key = record.Get("key")
+		}
+
+		err = b.Put(key, record.Get("value"))
+		if err != nil {
+			// don't commit the records before it.
+			tx := s.tx
+			if tx == nil {
+				tx = transactionOf(s.value)
+			}
+
+			if tx != nil {
+				tx.Abort()
+			}
+
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+	}
+
+	return b.Wait()
+}
+
+// a record of a store, from `Dump`.
+type Record struct {
+	Key   js.Value
+	Value js.Value
+}
+
+// read every record of every store in a single readonly transaction, by store name.
+// intended for inspecting the database while debugging or testing.
+func (db *DB) Dump() (map[string][]Record, error) {
+	names := db.StoreNames()
+	dump := make(map[string][]Record, len(names))
+
+	// a transaction needs at least 1 store.
+	if len(names) == 0 {
+		return dump, nil
+	}
+
+	err := db.View(names, func(tx *Transaction) error {
+		for _, name := range names {
+			cur, err := tx.Store(name).OpenCursor(nil, Next)
+			if err != nil {
+				return fmt.Errorf("store %s: %w", name, err)
+			}
+
+			records := []Record{}
+
+			for cur.Valid() {
+				records = append(records, Record{
+					Key:   cur.PrimaryKey(),
+					Value: cur.Value(),
+				})
+
+				err = cur.Continue(nil)
+				if err != nil {
+					return fmt.Errorf("store %s: %w", name, err)
+				}
+			}
+
+			dump[name] = records
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return dump, nil
+}
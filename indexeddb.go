@@ -3,19 +3,33 @@
 package indexeddb
 
 import (
+	"cmp"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"math"
 	"reflect"
+	"slices"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"syscall/js"
+	"time"
 )
 
 var (
-	IndexedDB = js.Global().Get("indexedDB")
-	Object    = js.Global().Get("Object")
-	Array     = js.Global().Get("Array")
+	// the `IDBFactory` used to open, delete and list databases.
+	// reassign it to use another, such as a polyfill or the `indexedDB` of a worker.
+	IndexedDB   = js.Global().Get("indexedDB")
+	Object      = js.Global().Get("Object")
+	Array       = js.Global().Get("Array")
+	JSON        = js.Global().Get("JSON")
+	Uint8Array  = js.Global().Get("Uint8Array")
+	ArrayBuffer = js.Global().Get("ArrayBuffer")
+	Date        = js.Global().Get("Date")
+	BigInt      = js.Global().Get("BigInt")
 )
 
 var (
@@ -23,10 +37,45 @@ var (
 	ErrKeyInvalid    = errors.New("key is invalid")
 	ErrValueInvalid  = errors.New("value is invalid")
 	ErrInvalidType   = errors.New("type is not accepted")
+	ErrTimeout       = errors.New("request timed out")
+	ErrBlocked       = errors.New("blocked by another open connection")
+	ErrVersionTooLow = errors.New("version is lower than the existing version")
 )
 
+// errors matching the name of a `DOMException`, use `errors.Is` to check a `*DBError`.
+var (
+	ErrConstraint          = errors.New("constraint error")
+	ErrQuotaExceeded       = errors.New("quota exceeded")
+	ErrData                = errors.New("data error")
+	ErrNotFound            = errors.New("not found")
+	ErrTransactionInactive = errors.New("transaction is inactive")
+	ErrAborted             = errors.New("aborted")
+	ErrVersion             = errors.New("version error")
+)
+
+var domErrors = map[string]error{
+	"ConstraintError":          ErrConstraint,
+	"QuotaExceededError":       ErrQuotaExceeded,
+	"DataError":                ErrData,
+	"NotFoundError":            ErrNotFound,
+	"TransactionInactiveError": ErrTransactionInactive,
+	"ReadOnlyError":            ErrReadOnly,
+	"AbortError":               ErrAborted,
+	"VersionError":             ErrVersion,
+}
+
+// how long to wait for a request to complete before returning `ErrTimeout`.
+// zero or less waits forever.
+var AwaitTimeout = 30 * time.Second
+
+// logs every operation at the debug level, discarded by default.
 var Logger *slog.Logger
 
+// check if debug logs are enabled, so the attributes aren't built when they'd be discarded.
+func debugging() bool {
+	return Logger.Enabled(context.Background(), slog.LevelDebug)
+}
+
 func init() {
 	// discard logs by default.
 	if Logger == nil {
@@ -36,220 +85,342 @@ func init() {
 
 type Store struct {
 	value js.Value
+
+	// the transaction the store was opened in, nil if it was wrapped.
+	tx *Transaction
+}
+
+// wrap an existing `IDBObjectStore`.
+func WrapStore(v js.Value) *Store {
+	return &Store{
+		value: v,
+	}
+}
+
+// the underlying `IDBObjectStore`.
+func (s *Store) JSValue() js.Value {
+	return s.value
+}
+
+// the name of the store.
+func (s *Store) Name() string {
+	return s.value.Get("name").String()
+}
+
+// return the error of the transaction, if it has failed the store can no longer be used.
+func (s *Store) check() error {
+	if s.tx == nil {
+		return nil
+	}
+
+	return s.tx.Err()
+}
+
+// make a request on the store, failing early if the transaction has failed.
+func (s *Store) request(method string, args ...any) (js.Value, error) {
+	err := s.check()
+	if err != nil {
+		return js.Value{}, err
+	}
+
+	return call(s.value, method, args...)
 }
 
 // keys and values can be pretty much anything in indexeddb.
-// we limit to strings, bools, ints, uints, floats, slices and javascript values.
+// we limit keys to strings, bools, ints, uints, floats, times, javascript values and slices of them.
+// float32 keys are widened to float64.
+// values are converted with `Codec`.
 //
 // values indexedb supports: https://developer.mozilla.org/en-US/docs/Web/API/IndexedDB_API/Basic_Terminology.
 // values Go supports: https://github.com/golang/go/blob/676002986c55a296ea348c30706d6b63a3256b7f/src/syscall/js/js.go#L152-L211.
 func valid(x any) error {
+	v := reflect.ValueOf(x)
+
+	// dereference pointers, such as optional fields.
+	for v.Kind() == reflect.Pointer {
+		// null is a value but never a key.
+		if v.IsNil() {
+			return errors.New("nil pointer not allowed as key")
+		}
+
+		v = v.Elem()
+	}
+
 	// check if the type is a javascript value.
-	if _, js := x.(js.Value); js {
+	// times are converted to a javascript date.
+	if v.IsValid() && (v.Type() == jsValueType || v.Type() == timeType) {
 		return nil
 	}
 
-	switch v := reflect.ValueOf(x); {
+	switch {
+	// indexeddb doesn't accept a bigint as a key, so large integers would lose precision.
+	case v.CanInt() && (v.Int() > maxSafeInteger || v.Int() < -maxSafeInteger):
+		return fmt.Errorf("integer %d is outside the safe range of a key", v.Int())
+
+	case v.CanUint() && v.Uint() > maxSafeInteger:
+		return fmt.Errorf("integer %d is outside the safe range of a key", v.Uint())
+
+	// indexeddb throws a cryptic error for NaN, infinity is a valid key.
+	case v.CanFloat() && math.IsNaN(v.Float()):
+		return errors.New("NaN not allowed as key")
+
 	// check if the value is a string, bool, int, uint or float.
 	case v.Kind() == reflect.String, v.Kind() == reflect.Bool, v.CanInt(), v.CanUint(), v.CanFloat():
 		return nil
 
+	// a nil slice would be null.
+	case v.Kind() == reflect.Slice && v.IsNil():
+		return errors.New("nil slice not allowed as key")
+
+	// slices and arrays become an array key, such as for a compound key path.
+	case v.Kind() == reflect.Slice, v.Kind() == reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			err := valid(v.Index(i).Interface())
+			if err != nil {
+				return fmt.Errorf("index %d: %w", i, err)
+			}
+		}
+
+		return nil
+
 	default:
 		return errors.Join(ErrInvalidType, fmt.Errorf("type: %T", x))
 	}
 }
 
 func (s *Store) put(key, value any) (js.Value, error) {
-	Logger.Debug("store put", "key", key, "value", value)
-
-	// ensure the key is valid if provided.
-	if key != nil {
-		err := valid(key)
-		if err != nil {
-			return js.Value{}, errors.Join(ErrKeyInvalid, err)
-		}
+	if debugging() {
+		Logger.Debug("store put", "store", s.Name(), "key", key, "value", value)
 	}
 
-	// the key should be undefined to be considered nil.
-	if key == nil {
-		key = js.Undefined()
-	}
-
-	err := valid(value)
+	k, val, err := s.record(key, value)
 	if err != nil {
-		return js.Value{}, errors.Join(ErrValueInvalid, err)
+		return js.Value{}, err
 	}
 
 	// put the key and value.
 	// the key is the 2nd argument as it's optional.
-	return s.value.Call("put", value, key), nil
+	return s.request("put", val, k)
 }
 
-// put is either an insert or an update,
-func (s *Store) Put(key any, value any) error {
-	req, err := s.put(key, value)
-	if err != nil {
-		return err
-	}
+// convert a key and value to javascript for a put or add.
+// without a key, a struct field tagged `idb:"key"` is used as the key.
+func (s *Store) record(key, value any) (js.Value, js.Value, error) {
+	// the key should be undefined to be considered nil.
+	k := js.Undefined()
 
-	// wait for the request to complete.
-	return await(req, nil)
-}
+	path := s.value.Get("keyPath")
 
-func (s *Store) add(key, value any) (js.Value, error) {
 	// ensure the key is valid if provided.
 	if key != nil {
-		err := valid(key)
+		// the key is taken from the value, indexeddb would fail with a `DataError`.
+		if !path.IsNull() && !path.IsUndefined() {
+			return js.Value{}, js.Value{}, errors.Join(ErrKeyInvalid, fmt.Errorf("store %s has in-line keys, the key must be nil", s.Name()))
+		}
+
+		var err error
+
+		k, err = toKey(key)
 		if err != nil {
-			return js.Value{}, errors.Join(ErrKeyInvalid, err)
+			return js.Value{}, js.Value{}, err
 		}
 	}
 
-	// ensure the value is valid.
-	err := valid(value)
+	// convert the value to javascript.
+	val, err := Codec.Marshal(value)
 	if err != nil {
-		return js.Value{}, errors.Join(ErrValueInvalid, err)
+		return js.Value{}, js.Value{}, errors.Join(ErrValueInvalid, err)
 	}
 
-	// the key should be undefined to be considered nil.
-	if key == nil {
-		key = js.Undefined()
+	if key != nil {
+		return k, val, nil
 	}
 
-	// add the value and optionally the key.
-	return s.value.Call("add", value, key), nil
+	sk, ok := structKey(value)
+	if !ok {
+		return k, val, nil
+	}
+
+	switch {
+	// the store has out-of-line keys, so the field is the key.
+	// an empty key is left for the store to generate if it auto increments.
+	case path.IsNull():
+		if sk.IsZero() && s.value.Get("autoIncrement").Truthy() {
+			return k, val, nil
+		}
+
+		k, err = toKey(sk.Interface())
+		if err != nil {
+			return js.Value{}, js.Value{}, err
+		}
+
+	// the store has in-line keys, copy the field to the key path.
+	case path.Type() == js.TypeString && !strings.Contains(path.String(), "."):
+		sv, err := toKey(sk.Interface())
+		if err != nil {
+			return js.Value{}, js.Value{}, err
+		}
+
+		val.Set(path.String(), sv)
+	}
+
+	return k, val, nil
 }
 
-func (s *Store) Add(key, value any) error {
-	req, err := s.add(key, value)
+// validate and convert a key to javascript.
+func toKey(x any) (js.Value, error) {
+	err := valid(x)
 	if err != nil {
-		return err
+		return js.Value{}, errors.Join(ErrKeyInvalid, err)
 	}
 
-	// wait for the request to complete.
-	return await(req, nil)
+	return Marshal(x)
 }
 
-// get is a query for the key.
-func (s *Store) Get(key any) (*js.Value, error) {
-	Logger.Debug("store get", "key", key)
+// put is either an insert or an update,
+func (s *Store) Put(key any, value any) error {
+	return s.PutContext(context.Background(), key, value)
+}
 
-	err := valid(key)
+func (s *Store) PutContext(ctx context.Context, key, value any) error {
+	req, err := s.put(key, value)
 	if err != nil {
-		return nil, errors.Join(ErrKeyInvalid, err)
+		return err
 	}
 
-	req := s.value.Call("get", key)
-
 	// wait for the request to complete.
-	err = await(req, nil)
+	return awaitContext(ctx, req, nil)
+}
+
+// put is an upsert, returning the key of the record.
+// useful for getting the generated key of an auto increment store or the key of an in-line key store.
+func (s *Store) PutKey(key, value any) (js.Value, error) {
+	req, err := s.put(key, value)
 	if err != nil {
-		return nil, err
+		return js.Value{}, err
 	}
 
-	res := req.Get("result")
-
-	// check if the result was not found.
-	if res.IsUndefined() {
-		return nil, ErrValueNotFound
+	// wait for the request to complete.
+	err = await(req, nil)
+	if err != nil {
+		return js.Value{}, err
 	}
 
-	// return the result.
-	return &res, nil
+	return req.Get("result"), nil
 }
 
-func (s *Store) Delete(key any) error {
-	err := valid(key)
+// put a value, reporting whether a record already existed for the key and was updated.
+// the key is required, as it's checked before the put within the same transaction.
+func (s *Store) PutResult(key, value any) (existed bool, err error) {
+	existed, err = s.Has(key)
 	if err != nil {
-		return errors.Join(ErrKeyInvalid, err)
+		return false, err
 	}
 
-	// make the request to delete the key.
-	req := s.value.Call("delete", key)
+	err = s.Put(key, value)
+	if err != nil {
+		return false, err
+	}
 
-	// wait for the request to complete.
-	return await(req, nil)
+	return existed, nil
 }
 
-func (s *Store) Clear() error {
-	// make the request to clear.
-	req := s.value.Call("clear")
-
-	// wait for the request to complete.
-	return await(req, nil)
-}
+// add delta to the number stored at the key, starting from 0 if it's not found, returning the new value.
+// the read and write happen in the store's transaction, so it's atomic against other transactions.
+func (s *Store) Increment(key any, delta int) (int, error) {
+	n, err := GetInt(s, key)
+	if err != nil && !errors.Is(err, ErrValueNotFound) {
+		return 0, err
+	}
 
-func (s *Store) Count() (int, error) {
-	req := s.value.Call("count")
+	n += delta
 
-	err := await(req, nil)
+	err = s.Put(key, n)
 	if err != nil {
 		return 0, err
 	}
 
-	return req.Get("result").Int(), nil
+	return n, nil
 }
 
-func (s *Store) GetAll() (*js.Value, error) {
-	req := s.value.Call("getAll")
+// update puts a value into a store with in-line keys, where the key is taken from the value.
+func (s *Store) Update(value any) error {
+	return s.Put(nil, value)
+}
 
-	// wait for the request to complete.
-	err := await(req, nil)
-	if err != nil {
-		return nil, err
+func (s *Store) add(key, value any) (js.Value, error) {
+	if debugging() {
+		Logger.Debug("store add", "store", s.Name(), "key", key, "value", value)
 	}
 
-	res := req.Get("result")
-
-	// check if the result was not found.
-	if res.IsUndefined() {
-		return nil, ErrValueNotFound
+	k, val, err := s.record(key, value)
+	if err != nil {
+		return js.Value{}, err
 	}
 
-	return &res, nil
+	// add the value and optionally the key.
+	return s.request("add", val, k)
 }
 
-func (s *Store) Batch() *Batch {
-	return &Batch{
-		store: s,
+func (s *Store) Add(key, value any) error {
+	return s.AddContext(context.Background(), key, value)
+}
 
-		doneChan: make(chan struct{}),
-		errChan:  make(chan error),
+func (s *Store) AddContext(ctx context.Context, key, value any) error {
+	req, err := s.add(key, value)
+	if err != nil {
+		return err
 	}
-}
 
-func (s *Store) Index(name string) *Index {
-	val := s.value.Call("index", name)
+	// wait for the request to complete.
+	return awaitContext(ctx, req, nil)
+}
 
-	return &Index{
-		value: val,
-	}
+// insert adds a value into a store with in-line keys, where the key is taken from the value.
+func (s *Store) Insert(value any) error {
+	return s.Add(nil, value)
 }
 
-func (s *Store) NewIndex(name string) *Index {
-	val := s.value.Call("createIndex", name, name)
+// add is an insert, returning the key of the new record.
+// useful for getting the generated key of an auto increment store.
+func (s *Store) AddKey(key, value any) (js.Value, error) {
+	req, err := s.add(key, value)
+	if err != nil {
+		return js.Value{}, err
+	}
 
-	return &Index{
-		value: val,
+	// wait for the request to complete.
+	err = await(req, nil)
+	if err != nil {
+		return js.Value{}, err
 	}
+
+	return req.Get("result"), nil
 }
 
-type Index struct {
-	value js.Value
+// get is a query for the key.
+// a record stored with a nil value returns null, `ErrValueNotFound` is only returned if there is no record.
+func (s *Store) Get(key any) (*js.Value, error) {
+	return s.GetContext(context.Background(), key)
 }
 
-func (i *Index) Get(key any) (*js.Value, error) {
-	Logger.Debug("index get", "key", key)
+func (s *Store) GetContext(ctx context.Context, key any) (*js.Value, error) {
+	if debugging() {
+		Logger.Debug("store get", "store", s.Name(), "key", key)
+	}
 
-	err := valid(key)
+	k, err := toKey(key)
 	if err != nil {
-		return nil, errors.Join(ErrKeyInvalid, err)
+		return nil, err
 	}
 
-	req := i.value.Call("get", key)
+	req, err := s.request("get", k)
+	if err != nil {
+		return nil, err
+	}
 
 	// wait for the request to complete.
-	err = await(req, nil)
+	err = awaitContext(ctx, req, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -265,287 +436,1665 @@ func (i *Index) Get(key any) (*js.Value, error) {
 	return &res, nil
 }
 
-type Batch struct {
-	store *Store
+// get the values for many keys at once, the results are in the same order as the keys.
+// a result is nil if the key was not found.
+func (s *Store) GetMany(keys []any) ([]*js.Value, error) {
+	b := s.Batch()
 
-	count int
-	ready atomic.Bool
+	reqs := make([]js.Value, len(keys))
 
-	doneChan chan struct{}
-	errChan  chan error
-}
+	for i, key := range keys {
+		k, err := toKey(key)
+		if err != nil {
+			return nil, err
+		}
 
-func (b *Batch) await(req js.Value) {
-	listen(req, "onerror", func(v js.Value) {
-		for !b.ready.Load() {
+		reqs[i], err = s.request("get", k)
+		if err != nil {
+			return nil, err
 		}
 
-		b.errChan <- wrapError(v)
-	})
+		b.await("get", reqs[i])
+	}
+
+	// wait for every request to complete.
+	err := b.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*js.Value, len(reqs))
 
-	listen(req, "onsuccess", func(v js.Value) {
-		for !b.ready.Load() {
+	for i, req := range reqs {
+		v := req.Get("result")
+
+		// leave the result nil if it was not found.
+		if !v.IsUndefined() {
+			res[i] = &v
 		}
+	}
 
-		b.doneChan <- struct{}{}
-	})
+	return res, nil
+}
 
-	b.count++
+// delete the record for a key, or every record within a `*KeyRange`.
+func (s *Store) Delete(key any) error {
+	return s.DeleteContext(context.Background(), key)
 }
 
-func (b *Batch) Put(key, value any) error {
-	req, err := b.store.put(key, value)
-	if err != nil {
-		return err
+func (s *Store) delete(key any) (js.Value, error) {
+	if debugging() {
+		Logger.Debug("store delete", "store", s.Name(), "key", key)
 	}
 
-	b.await(req)
+	k, err := toQuery(key)
+	if err != nil {
+		return js.Value{}, err
+	}
 
-	return nil
+	// make the request to delete the key or range.
+	return s.request("delete", k)
 }
 
-func (b *Batch) Add(key, value any) error {
-	req, err := b.store.add(key, value)
+func (s *Store) DeleteContext(ctx context.Context, key any) error {
+	req, err := s.delete(key)
 	if err != nil {
 		return err
 	}
 
-	b.await(req)
-
-	return nil
+	// wait for the request to complete.
+	return awaitContext(ctx, req, nil)
 }
 
-func (b *Batch) Wait() error {
-	b.ready.Store(true)
+// delete every record within the range, a nil range deletes every record.
+// unlike `Clear`, the key generator of an auto increment store is kept, so new keys keep increasing.
+func (s *Store) DeleteAll(rng *KeyRange) error {
+	query := rng.query()
 
-	for b.count > 0 {
-		select {
-		case <-b.doneChan:
-			b.count -= 1
+	// every key is greater than negative infinity, the lowest number.
+	if rng == nil {
+		var err error
 
-		case err := <-b.errChan:
+		query, err = call(keyRange, "lowerBound", math.Inf(-1))
+		if err != nil {
 			return err
 		}
 	}
 
-	return nil
+	if debugging() {
+		Logger.Debug("store delete all", "store", s.Name(), "range", rng)
+	}
+
+	req, err := s.request("delete", query)
+	if err != nil {
+		return err
+	}
+
+	// wait for the request to complete.
+	return await(req, nil)
 }
 
-// an upgrade is a database connection before needing it's objects/indexes established.
-type Upgrade struct {
-	value js.Value
+// delete every record and reset the key generator of an auto increment store.
+func (s *Store) Clear() error {
+	if debugging() {
+		Logger.Debug("store clear", "store", s.Name())
+	}
+
+	// make the request to clear.
+	req, err := s.request("clear")
+	if err != nil {
+		return err
+	}
+
+	// wait for the request to complete.
+	return await(req, nil)
 }
 
-type StoreConfig struct {
-	KeyPath       string
-	AutoIncrement bool
+// count every record in the store, use `CountRange` to count within a range.
+func (s *Store) Count() (int, error) {
+	return s.CountContext(context.Background())
 }
 
-func (up *Upgrade) NewStore(name string, cfg *StoreConfig) *Store {
-	opts := js.Undefined()
+func (s *Store) CountContext(ctx context.Context) (int, error) {
+	if debugging() {
+		Logger.Debug("store count", "store", s.Name())
+	}
 
-	if cfg != nil {
-		opts = Object.New()
+	err := s.check()
+	if err != nil {
+		return 0, err
+	}
 
-		if cfg.KeyPath != "" {
-			opts.Set("keyPath", cfg.KeyPath)
-		}
+	return count(ctx, s.value, js.Undefined())
+}
 
-		if cfg.AutoIncrement {
-			opts.Set("autoIncrement", true)
-		}
+// count the records within the range.
+func (s *Store) CountRange(rng *KeyRange) (int, error) {
+	if debugging() {
+		Logger.Debug("store count", "store", s.Name(), "range", rng)
 	}
 
-	// create a new object store.
-	val := up.value.Call("createObjectStore", name, opts)
-
-	return &Store{
-		value: val,
+	err := s.check()
+	if err != nil {
+		return 0, err
 	}
-}
 
-// deprecated use `NewStore` instead.
-func (up *Upgrade) CreateStore(name string) {
-	up.NewStore(name, nil)
+	return count(context.Background(), s.value, rng.query())
 }
 
-type Mode int
+// check if a record exists for the key, without getting the value.
+func (s *Store) Has(key any) (bool, error) {
+	k, err := toKey(key)
+	if err != nil {
+		return false, err
+	}
 
-const (
+	err = s.check()
+	if err != nil {
+		return false, err
+	}
+
+	n, err := count(context.Background(), s.value, k)
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// get all the values matching the query, either a key or a `*KeyRange`, nil matches every key.
+// a limit of 0 returns every match.
+func (s *Store) GetAll(query any, limit int) ([]js.Value, error) {
+	err := s.check()
+	if err != nil {
+		return nil, err
+	}
+
+	return getAll(s.value, "getAll", query, limit)
+}
+
+// get all the keys matching the query, either a key or a `*KeyRange`, without the values.
+// a limit of 0 returns every match.
+func (s *Store) GetAllKeys(query any, limit int) ([]js.Value, error) {
+	err := s.check()
+	if err != nil {
+		return nil, err
+	}
+
+	return getAll(s.value, "getAllKeys", query, limit)
+}
+
+func (s *Store) Batch() *Batch {
+	return &Batch{
+		store: s,
+	}
+}
+
+// put every key and value in a single batch, returning the first error.
+func (s *Store) PutAll(items map[any]any) error {
+	b := s.Batch()
+
+	for key, value := range items {
+		err := b.Put(key, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return b.Wait()
+}
+
+// a key and value to put, the key is nil for a store with in-line keys.
+type Entry struct {
+	Key   any
+	Value any
+}
+
+// put every entry then wait for the transaction to commit, instead of waiting for each request.
+// the transaction is finished afterwards, so no other operations can be made in it.
+func (s *Store) PutBatch(entries []Entry) error {
+	tx := s.tx
+
+	// find the transaction of a wrapped store.
+	if tx == nil {
+		tx = transactionOf(s.value)
+	}
+
+	if tx == nil {
+		return errors.New("store is not within a watched transaction")
+	}
+
+	for i, e := range entries {
+		_, err := s.put(e.Key, e.Value)
+		if err != nil {
+			// don't commit the entries before it.
+			tx.Abort()
+
+			return fmt.Errorf("entry #%d: %w", i, err)
+		}
+	}
+
+	// a failed put aborts the transaction with its error.
+	return tx.Done()
+}
+
+func (s *Store) Index(name string) *Index {
+	val := s.value.Call("index", name)
+
+	return &Index{
+		value: val,
+	}
+}
+
+// create an index using the name as the key path.
+func (s *Store) NewIndex(name string) *Index {
+	return s.CreateIndex(name, name, nil)
+}
+
+// the names of the indexes on the store.
+func (s *Store) IndexNames() []string {
+	return StringList(s.value.Get("indexNames"))
+}
+
+// delete an index, only possible within an upgrade.
+func (s *Store) DeleteIndex(name string) {
+	s.value.Call("deleteIndex", name)
+}
+
+type IndexConfig struct {
+	// each key can only be used by a single record.
+	Unique bool
+	// an array key path adds an entry for each element of the array.
+	MultiEntry bool
+	// multiple key paths create a compound index, taking precedence over the key path.
+	// a compound index can't be multi entry.
+	KeyPaths []string
+}
+
+// create an index on the key path, which can differ from the name, such as "byEmail" on "email".
+func (s *Store) CreateIndex(name, keyPath string, cfg *IndexConfig) *Index {
+	opts := js.Undefined()
+	path := js.ValueOf(keyPath)
+
+	if cfg != nil {
+		opts = Object.New()
+
+		if cfg.Unique {
+			opts.Set("unique", true)
+		}
+
+		if cfg.MultiEntry {
+			opts.Set("multiEntry", true)
+		}
+
+		if len(cfg.KeyPaths) > 0 {
+			path = toKeyPath(cfg.KeyPaths)
+		}
+	}
+
+	// create a new index.
+	val := s.value.Call("createIndex", name, path, opts)
+
+	return &Index{
+		value: val,
+	}
+}
+
+type Index struct {
+	value js.Value
+}
+
+// wrap an existing `IDBIndex`.
+func WrapIndex(v js.Value) *Index {
+	return &Index{
+		value: v,
+	}
+}
+
+// the underlying `IDBIndex`.
+func (i *Index) JSValue() js.Value {
+	return i.value
+}
+
+// the name of the index.
+func (i *Index) Name() string {
+	return i.value.Get("name").String()
+}
+
+func (i *Index) Get(key any) (*js.Value, error) {
+	if debugging() {
+		Logger.Debug("index get", "index", i.Name(), "key", key)
+	}
+
+	k, err := toKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := call(i.value, "get", k)
+	if err != nil {
+		return nil, err
+	}
+
+	// wait for the request to complete.
+	err = await(req, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res := req.Get("result")
+
+	// check if the result was not found.
+	if res.IsUndefined() {
+		return nil, ErrValueNotFound
+	}
+
+	// return the result.
+	return &res, nil
+}
+
+// get the primary key of the first record matching the index key, without the value.
+func (i *Index) GetKey(key any) (*js.Value, error) {
+	if debugging() {
+		Logger.Debug("index get key", "index", i.Name(), "key", key)
+	}
+
+	k, err := toKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := call(i.value, "getKey", k)
+	if err != nil {
+		return nil, err
+	}
+
+	// wait for the request to complete.
+	err = await(req, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res := req.Get("result")
+
+	// check if the result was not found.
+	if res.IsUndefined() {
+		return nil, ErrValueNotFound
+	}
+
+	// return the result.
+	return &res, nil
+}
+
+// get all the values matching the query of the index, either a key or a `*KeyRange`, nil matches every key.
+// a limit of 0 returns every match.
+func (i *Index) GetAll(query any, limit int) ([]js.Value, error) {
+	return getAll(i.value, "getAll", query, limit)
+}
+
+// get the primary keys of the records matching the query of the index, without the values.
+// a limit of 0 returns every match.
+func (i *Index) GetAllKeys(query any, limit int) ([]js.Value, error) {
+	return getAll(i.value, "getAllKeys", query, limit)
+}
+
+// count the records within the range of the index, a nil range matches every key.
+func (i *Index) Count(rng *KeyRange) (int, error) {
+	return count(context.Background(), i.value, rng.query())
+}
+
+// count the records sharing the index key, such as how many people are a given age.
+func (i *Index) CountByKey(key any) (int, error) {
+	k, err := toKey(key)
+	if err != nil {
+		return 0, err
+	}
+
+	return count(context.Background(), i.value, k)
+}
+
+// check if any record has the index key, without getting the value.
+func (i *Index) Has(key any) (bool, error) {
+	n, err := i.CountByKey(key)
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+type Batch struct {
+	store *Store
+
+	pending []batchRequest
+
+	// the keys of the put and add requests from the last successful wait.
+	keys []js.Value
+}
+
+type batchRequest struct {
+	op  string
+	req js.Value
+
+	// buffered so the event handlers never block.
+	errChan chan error
+
+	// remove the handlers, once we've stopped waiting.
+	release func()
+}
+
+func (b *Batch) await(op string, req js.Value) {
+	errChan := make(chan error, 1)
+
+	b.pending = append(b.pending, batchRequest{
+		op:      op,
+		req:     req,
+		errChan: errChan,
+		release: listenResult(req, errChan),
+	})
+}
+
+func (b *Batch) Put(key, value any) error {
+	req, err := b.store.put(key, value)
+	if err != nil {
+		return err
+	}
+
+	b.await("put", req)
+
+	return nil
+}
+
+func (b *Batch) Add(key, value any) error {
+	req, err := b.store.add(key, value)
+	if err != nil {
+		return err
+	}
+
+	b.await("add", req)
+
+	return nil
+}
+
+func (b *Batch) Delete(key any) error {
+	req, err := b.store.delete(key)
+	if err != nil {
+		return err
+	}
+
+	b.await("delete", req)
+
+	return nil
+}
+
+// clear every record of the store, puts made after it in the batch are kept.
+func (b *Batch) Clear() error {
+	req, err := b.store.request("clear")
+	if err != nil {
+		return err
+	}
+
+	b.await("clear", req)
+
+	return nil
+}
+
+func (b *Batch) Wait() error {
+	pending := b.pending
+	b.pending = nil
+	b.keys = nil
+
+	// remove the handlers of any request we don't wait for.
+	defer func() {
+		for _, p := range pending {
+			p.release()
+		}
+	}()
+
+	var keys []js.Value
+
+	for i, p := range pending {
+		err := wait(context.Background(), b.store.tx, p.errChan, AwaitTimeout)
+		if err != nil {
+			return fmt.Errorf("batch %s #%d failed: %w", p.op, i, err)
+		}
+
+		// the result of a put or add is the key of the record.
+		if p.op == "put" || p.op == "add" {
+			keys = append(keys, p.req.Get("result"))
+		}
+	}
+
+	b.keys = keys
+
+	return nil
+}
+
+// the keys of every put and add, in the order they were made, after a successful `Wait`.
+func (b *Batch) Keys() []js.Value {
+	return b.keys
+}
+
+// an upgrade is a database connection before needing it's objects/indexes established.
+type Upgrade struct {
+	value js.Value
+
+	// the versionchange transaction the upgrade runs in.
+	tx js.Value
+}
+
+type StoreConfig struct {
+	KeyPath string
+	// multiple key paths create a compound key, taking precedence over `KeyPath`.
+	KeyPaths      []string
+	AutoIncrement bool
+}
+
+func (up *Upgrade) NewStore(name string, cfg *StoreConfig) *Store {
+	opts := js.Undefined()
+
+	if cfg != nil {
+		opts = Object.New()
+
+		if len(cfg.KeyPaths) > 0 {
+			opts.Set("keyPath", toKeyPath(cfg.KeyPaths))
+		} else if cfg.KeyPath != "" {
+			opts.Set("keyPath", cfg.KeyPath)
+		}
+
+		if cfg.AutoIncrement {
+			opts.Set("autoIncrement", true)
+		}
+	}
+
+	// create a new object store.
+	val := up.value.Call("createObjectStore", name, opts)
+
+	return &Store{
+		value: val,
+	}
+}
+
+// get an existing object store within the upgrade transaction, to read and rewrite it's records.
+func (up *Upgrade) Store(name string) *Store {
+	return &Store{
+		value: up.tx.Call("objectStore", name),
+	}
+}
+
+// delete an object store and all of its records.
+func (up *Upgrade) DeleteStore(name string) {
+	up.value.Call("deleteObjectStore", name)
+}
+
+// delete an index from an existing object store.
+func (up *Upgrade) DeleteIndex(store, name string) {
+	up.tx.Call("objectStore", store).Call("deleteIndex", name)
+}
+
+// a single key path is a string, multiple key paths are an array.
+func toKeyPath(paths []string) js.Value {
+	if len(paths) == 1 {
+		return js.ValueOf(paths[0])
+	}
+
+	arr := Array.New()
+
+	for _, path := range paths {
+		arr.Call("push", path)
+	}
+
+	return arr
+}
+
+// deprecated use `NewStore` instead.
+func (up *Upgrade) CreateStore(name string) {
+	up.NewStore(name, nil)
+}
+
+type Mode int
+
+const (
 	ReadMode Mode = iota
 	ReadWriteMode
 )
 
-var modes = [...]string{
-	ReadMode:      "readonly",
-	ReadWriteMode: "readwrite",
+var modes = [...]string{
+	ReadMode:      "readonly",
+	ReadWriteMode: "readwrite",
+}
+
+func (m Mode) Verify() bool {
+	return m == ReadMode || m == ReadWriteMode
+}
+
+func (m Mode) String() string {
+	return modes[int(m)]
+}
+
+// a transaction commits once it has no pending requests when control returns to the event loop.
+// awaiting requests one after another keeps it active, as each request is made while handling the previous one.
+// waiting on anything else in between, such as a timer or `fetch`, lets it commit.
+//
+// https://developer.mozilla.org/en-US/docs/Web/API/IDBTransaction.
+type Transaction struct {
+	value js.Value
+
+	mu  sync.Mutex
+	err error
+
+	// closed once the transaction has completed or aborted.
+	done chan struct{}
+}
+
+// every watched transaction, so a request can find the transaction it was made in.
+var (
+	transactionsMu sync.Mutex
+
+	// the transactions by id.
+	transactions      = make(map[int]*Transaction)
+	nextTransactionID int
+
+	// the id of each `IDBTransaction`, weak so finished transactions can be collected.
+	transactionIDs = js.Global().Get("WeakMap").New()
+)
+
+// find the watched transaction a request was made in, nil if it has finished or isn't watched.
+func transactionOf(req js.Value) *Transaction {
+	if req.Get("transaction").Type() != js.TypeObject {
+		return nil
+	}
+
+	transactionsMu.Lock()
+	defer transactionsMu.Unlock()
+
+	id := transactionIDs.Call("get", req.Get("transaction"))
+	if id.IsUndefined() {
+		return nil
+	}
+
+	return transactions[id.Int()]
+}
+
+// wrap an existing `IDBTransaction`, it must not have finished yet.
+func WrapTransaction(v js.Value) *Transaction {
+	tx := &Transaction{
+		value: v,
+		done:  make(chan struct{}),
+	}
+
+	// record errors instead of panicking.
+	tx.watch()
+
+	return tx
+}
+
+// the underlying `IDBTransaction`.
+func (tx *Transaction) JSValue() js.Value {
+	return tx.value
+}
+
+// the first error of the transaction, such as a failed request.
+func (tx *Transaction) Err() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	return tx.err
+}
+
+// record the error if it's the first.
+func (tx *Transaction) fail(err error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.err == nil {
+		tx.err = err
+	}
+}
+
+// wait for the transaction to commit, returning an error if it aborted.
+// data is only durably persisted once the transaction completes.
+func (tx *Transaction) Done() error {
+	// a nil channel never receives, so we wait forever if there is no timeout.
+	var timeout <-chan time.Time
+
+	if AwaitTimeout > 0 {
+		timeout = time.After(AwaitTimeout)
+	}
+
+	select {
+	case <-tx.done:
+		return tx.Err()
+
+	case <-timeout:
+		return ErrTimeout
+	}
+}
+
+// abort the transaction, rolling back every change made within it.
+// any operation on the transaction afterwards will fail, and `Done` returns `ErrAborted`.
+func (tx *Transaction) Abort() error {
+	// the browser throws if the transaction has already finished.
+	_, err := call(tx.value, "abort")
+
+	return err
+}
+
+// watch the events of the transaction until it finishes.
+func (tx *Transaction) watch() {
+	var handlers []js.Func
+
+	transactionsMu.Lock()
+
+	nextTransactionID++
+	id := nextTransactionID
+
+	transactions[id] = tx
+	transactionIDs.Call("set", tx.value, id)
+
+	transactionsMu.Unlock()
+
+	// release the handlers once the transaction has finished.
+	finish := func() {
+		for _, h := range handlers {
+			h.Release()
+		}
+
+		transactionsMu.Lock()
+		delete(transactions, id)
+		transactionsMu.Unlock()
+
+		close(tx.done)
+	}
+
+	handlers = append(handlers,
+		// every failed request fires an error on the transaction.
+		listenAll(tx.value, "error", func(v js.Value) {
+			tx.fail(wrapError(v))
+		}),
+		listenAll(tx.value, "complete", func(v js.Value) {
+			if debugging() {
+				Logger.Debug("transaction complete")
+			}
+
+			finish()
+		}),
+		listenAll(tx.value, "abort", func(v js.Value) {
+			// the transaction has no error if it was aborted explicitly.
+			if tx.value.Get("error").IsNull() {
+				tx.fail(ErrAborted)
+			} else {
+				tx.fail(wrapError(tx.value.Get("error")))
+			}
+
+			if debugging() {
+				Logger.Debug("transaction abort", "err", tx.Err())
+			}
+
+			finish()
+		}),
+	)
+}
+
+func (tx *Transaction) Store(name string) *Store {
+	// get the store.
+	val := tx.value.Call("objectStore", name)
+
+	return &Store{
+		value: val,
+		tx:    tx,
+	}
+}
+
+// get every store within the scope of the transaction, by name.
+func (tx *Transaction) Stores() map[string]*Store {
+	names := StringList(tx.value.Get("objectStoreNames"))
+	stores := make(map[string]*Store, len(names))
+
+	for _, name := range names {
+		stores[name] = tx.Store(name)
+	}
+
+	return stores
+}
+
+// https://developer.mozilla.org/en-US/docs/Web/API/IDBDatabase.
+type DB struct {
+	value js.Value
+
+	// released when the database is closed.
+	onVersionChange js.Func
+}
+
+// wrap an existing `IDBDatabase`.
+// unlike `New`, versionchange events are left to the caller.
+func WrapDB(v js.Value) *DB {
+	return &DB{
+		value: v,
+	}
+}
+
+// the underlying `IDBDatabase`.
+func (db *DB) JSValue() js.Value {
+	return db.value
+}
+
+// a hint for how durably a transaction is written to disk before it completes.
+type Durability int
+
+const (
+	// let the browser decide.
+	DefaultDurability Durability = iota
+	// wait for the data to be flushed to disk.
+	StrictDurability
+	// complete once the data is written to the operating system, faster but can be lost on a crash.
+	RelaxedDurability
+)
+
+var durabilities = [...]string{
+	DefaultDurability: "default",
+	StrictDurability:  "strict",
+	RelaxedDurability: "relaxed",
+}
+
+func (d Durability) Verify() bool {
+	return d >= DefaultDurability && d <= RelaxedDurability
+}
+
+func (d Durability) String() string {
+	return durabilities[int(d)]
+}
+
+type TransactionConfig struct {
+	Durability Durability
+}
+
+func (db *DB) NewTransaction(stores []string, mode Mode) (*Transaction, error) {
+	return db.NewTransactionWithConfig(stores, mode, nil)
+}
+
+func (db *DB) NewTransactionWithConfig(stores []string, mode Mode, cfg *TransactionConfig) (*Transaction, error) {
+	// ensure we have at least 1 store.
+	if len(stores) == 0 {
+		return nil, errors.New("at least 1 store must be requested")
+	}
+
+	// ensure the mode if valid.
+	if !mode.Verify() {
+		return nil, errors.New("mode must be read or read write")
+	}
+
+	// create a new javascript array.
+	strs := Array.New()
+
+	// HACK: create a javascript array of strings from our Go slice of strings
+	// Go does not do this by default.
+	for _, str := range stores {
+		// append to the javascript array.
+		strs.Call("push", str)
+	}
+
+	opts := js.Undefined()
+
+	if cfg != nil {
+		// ensure the durability is valid.
+		if !cfg.Durability.Verify() {
+			return nil, errors.New("durability must be default, strict or relaxed")
+		}
+
+		opts = Object.New()
+		opts.Set("durability", cfg.Durability.String())
+	}
+
+	if debugging() {
+		Logger.Debug("transaction open", "stores", stores, "mode", mode)
+	}
+
+	// create the transaction.
+	// the browser throws if the connection was closed, such as when another connection upgraded the database.
+	val, err := call(db.value, "transaction", strs, mode.String(), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return WrapTransaction(val), nil
+}
+
+// run fn in a new readonly transaction, waiting for it to complete.
+// fn must not wait on anything other than requests, otherwise the transaction commits early.
+func (db *DB) View(scope []string, fn func(tx *Transaction) error) error {
+	return db.try(scope, ReadMode, fn)
+}
+
+// run fn in a new readwrite transaction, waiting for it to commit.
+// if fn returns an error the transaction is aborted and every change is rolled back.
+// fn must not wait on anything other than requests, otherwise the transaction commits early.
+func (db *DB) Update(scope []string, fn func(tx *Transaction) error) error {
+	return db.try(scope, ReadWriteMode, fn)
+}
+
+// the names of a `DOMException` that may succeed if the transaction is tried again.
+var retryable = map[string]bool{
+	"AbortError":               true,
+	"TransactionInactiveError": true,
+	"TimeoutError":             true,
+	"UnknownError":             true,
+}
+
+// run fn in a new transaction, trying again with a fresh transaction if it fails with a retryable error.
+// fn is called at most `attempts` times, an explicit `Abort` is not retried.
+func (db *DB) WithRetry(stores []string, mode Mode, fn func(tx *Transaction) error, attempts int) error {
+	var err error
+
+	for i := 0; i < max(attempts, 1); i++ {
+		err = db.try(stores, mode, fn)
+		if !isRetryable(err) {
+			return err
+		}
+
+		if debugging() {
+			Logger.Debug("retrying transaction", "attempt", i+1, "err", err)
+		}
+	}
+
+	return err
+}
+
+// run fn in a new transaction and wait for it to commit, aborting it if fn fails.
+func (db *DB) try(stores []string, mode Mode, fn func(tx *Transaction) error) error {
+	tx, err := db.NewTransaction(stores, mode)
+	if err != nil {
+		return err
+	}
+
+	err = fn(tx)
+	if err != nil {
+		// the transaction may have already aborted.
+		tx.Abort()
+
+		return err
+	}
+
+	return tx.Done()
+}
+
+// check if the error is a `DOMException` that is worth retrying.
+func isRetryable(err error) bool {
+	var dbErr *DBError
+
+	return errors.As(err, &dbErr) && retryable[dbErr.Name]
+}
+
+// the name of the database.
+func (db *DB) Name() string {
+	return db.value.Get("name").String()
+}
+
+// the version of the database.
+func (db *DB) Version() int {
+	return db.value.Get("version").Int()
+}
+
+// the names of the object stores in the database.
+func (db *DB) StoreNames() []string {
+	return StringList(db.value.Get("objectStoreNames"))
+}
+
+// close the database.
+func (db *DB) Close() error {
+	db.value.Call("close")
+
+	// a wrapped database has no handler.
+	if !db.onVersionChange.IsUndefined() {
+		db.onVersionChange.Release()
+	}
+
+	return nil
+}
+
+type OpenConfig struct {
+	// called if the database doesn't exist or is older than the version.
+	// the old version is 0 if the database is being created.
+	Upgrade func(up *Upgrade, oldVersion, newVersion int) error
+
+	// called when another connection is preventing the upgrade, such as another tab.
+	// the open continues once the other connections close.
+	OnBlocked func()
+
+	// called when another connection wants to upgrade or delete the database.
+	// the connection should be closed so it doesn't block, which is done by default if this is nil.
+	OnVersionChange func()
+
+	// how long to wait for the database to open, including any upgrade or blocked time.
+	// zero uses `AwaitTimeout`, less than zero waits forever.
+	Timeout time.Duration
+
+	// the `IDBFactory` to open the database with, undefined uses `IndexedDB`.
+	Factory js.Value
+}
+
+// open the database, calling upgrade if it doesn't exist or is older than the version.
+// the old version is 0 if the database is being created.
+func New(name string, version int, upgrade func(up *Upgrade, oldVersion, newVersion int) error) (*DB, error) {
+	return NewWithConfig(name, version, &OpenConfig{
+		Upgrade: upgrade,
+	})
+}
+
+func NewWithConfig(name string, version int, cfg *OpenConfig) (*DB, error) {
+	if cfg == nil {
+		cfg = &OpenConfig{}
+	}
+
+	// room for both a failed upgrade and the open failing once it's aborted.
+	errChan := make(chan error, 2)
+
+	factory := IndexedDB
+
+	if !cfg.Factory.IsUndefined() {
+		factory = cfg.Factory
+	}
+
+	// open the database.
+	req := factory.Call("open", name, version)
+
+	// handle the blocked event.
+	if cfg.OnBlocked != nil {
+		release := listen(req, "onblocked", func(v js.Value) {
+			cfg.OnBlocked()
+		})
+
+		// an open that isn't blocked never fires it.
+		defer release()
+	}
+
+	// handle the upgrade event.
+	release := listen(req, "onupgradeneeded", func(v js.Value) {
+		// nothing to upgrade.
+		if cfg.Upgrade == nil {
+			return
+		}
+
+		// get the database connection.
+		val := v.Get("target").Get("result")
+
+		// create a upgrade.
+		up := &Upgrade{
+			value: val,
+			tx:    v.Get("target").Get("transaction"),
+		}
+
+		oldVersion, newVersion := v.Get("oldVersion").Int(), v.Get("newVersion").Int()
+
+		// call the upgrade event in a goroutine, so it can wait for requests without blocking the event loop.
+		// it runs before the event returns, until it waits, so the transaction is still active.
+		go func() {
+			err := cfg.Upgrade(up, oldVersion, newVersion)
+			if err != nil {
+				errChan <- err
+
+				// roll back the upgrade, so the version is unchanged and it runs again on the next open.
+				// the browser throws if the transaction has already finished.
+				call(up.tx, "abort")
+			}
+		}()
+	})
+
+	// an open that doesn't upgrade never fires it.
+	defer release()
+
+	timeout := AwaitTimeout
+
+	if cfg.Timeout != 0 {
+		timeout = cfg.Timeout
+	}
+
+	err := awaitTimeout(context.Background(), req, errChan, timeout)
+	if err != nil {
+		// the open may still succeed after we've given up, such as after a timeout.
+		closeLate(req)
+	}
+
+	if errors.Is(err, ErrVersion) {
+		return nil, versionTooLow(factory, name, version, err)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	db := &DB{
+		value: req.Get("result"),
+	}
+
+	// handle another connection upgrading or deleting the database.
+	db.onVersionChange = listenAll(db.value, "versionchange", func(v js.Value) {
+		if cfg.OnVersionChange != nil {
+			cfg.OnVersionChange()
+			return
+		}
+
+		// close the connection so we don't block the other connection.
+		db.value.Call("close")
+	})
+
+	// return the database connection.
+	return db, nil
 }
 
-func (m Mode) Verify() bool {
-	return m == ReadMode || m == ReadWriteMode
-}
+// close the connection of an open request that was given up on, now or once it succeeds.
+func closeLate(req js.Value) {
+	// the transaction is only cleared once the upgrade has finished.
+	if req.Get("readyState").String() == "done" && req.Get("error").IsNull() && req.Get("transaction").IsNull() {
+		req.Get("result").Call("close")
+		return
+	}
 
-func (m Mode) String() string {
-	return modes[int(m)]
+	var releaseError func()
+
+	releaseSuccess := listen(req, "onsuccess", func(v js.Value) {
+		releaseError()
+
+		req.Get("result").Call("close")
+	})
+
+	releaseError = listen(req, "onerror", func(v js.Value) {
+		releaseSuccess()
+	})
 }
 
-// https://developer.mozilla.org/en-US/docs/Web/API/IDBTransaction.
-type Transaction struct {
-	value js.Value
+// a step in evolving the schema of a database, run when upgrading from an older version.
+type Migration struct {
+	Version int
+	Up      func(up *Upgrade) error
 }
 
-func (tx *Transaction) Store(name string) *Store {
-	// get the store.
-	val := tx.value.Call("objectStore", name)
+// open the database at the version of the newest migration, running every migration newer than the existing version in order.
+func Open(name string, migrations []Migration) (*DB, error) {
+	if len(migrations) == 0 {
+		return nil, errors.New("at least 1 migration must be provided")
+	}
 
-	return &Store{
-		value: val,
+	// sort a copy so the caller's order doesn't matter.
+	sorted := slices.Clone(migrations)
+
+	slices.SortStableFunc(sorted, func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+
+	for i, m := range sorted {
+		if m.Version < 1 {
+			return nil, fmt.Errorf("migration version %d must be at least 1", m.Version)
+		}
+
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, fmt.Errorf("duplicate migration version %d", m.Version)
+		}
 	}
-}
 
-// https://developer.mozilla.org/en-US/docs/Web/API/IDBDatabase.
-type DB struct {
-	value js.Value
+	version := sorted[len(sorted)-1].Version
+
+	return New(name, version, func(up *Upgrade, oldVersion, newVersion int) error {
+		for _, m := range sorted {
+			if m.Version <= oldVersion {
+				continue
+			}
+
+			err := m.Up(up)
+			if err != nil {
+				return fmt.Errorf("migration %d: %w", m.Version, err)
+			}
+		}
+
+		return nil
+	})
 }
 
-func (db *DB) NewTransaction(stores []string, mode Mode) (*Transaction, error) {
-	// ensure we have at least 1 store.
-	if len(stores) == 0 {
-		return nil, errors.New("at least 1 store must be requested")
+// explain a `VersionError`, which is fired when opening with a version lower than the existing version.
+func versionTooLow(factory js.Value, name string, version int, err error) error {
+	infos, lerr := databases(factory)
+
+	// fallback to the original error if we can't find the existing version.
+	if lerr != nil {
+		return errors.Join(ErrVersionTooLow, err)
 	}
 
-	// ensure the mode if valid.
-	if !mode.Verify() {
-		return nil, errors.New("mode must be read or read write")
+	for _, info := range infos {
+		if info.Name == name {
+			return errors.Join(ErrVersionTooLow, fmt.Errorf("requested version %d but the existing version is %d: %w", version, info.Version, err))
+		}
 	}
 
-	// create a new javascript array.
-	strs := Array.New()
+	return errors.Join(ErrVersionTooLow, err)
+}
 
-	// HACK: create a javascript array of strings from our Go slice of strings
-	// Go does not do this by default.
-	for _, str := range stores {
-		// append to the javascript array.
-		strs.Call("push", str)
-	}
+// delete the database.
+// if another connection is open `ErrBlocked` is returned, the database is still deleted once every connection closes.
+func Delete(name string) error {
+	errChan := make(chan error, 1)
 
-	// create the transaction.
-	val := db.value.Call("transaction", strs, mode.String())
+	// delete the database.
+	req := IndexedDB.Call("deleteDatabase", name)
 
-	// handle the error event.
-	listen(val, "onerror", func(v js.Value) {
-		// wrap and return the error event.
-		panic(wrapError(v))
+	// handle the blocked event, otherwise we would wait until every other connection closes.
+	release := listen(req, "onblocked", func(v js.Value) {
+		errChan <- ErrBlocked
 	})
 
-	return &Transaction{
-		value: val,
-	}, nil
+	// a delete that isn't blocked never fires it.
+	defer release()
+
+	return await(req, errChan)
 }
 
-func (db *DB) View(scope []string, fn func(tx *Transaction) error) error {
-	tx, err := db.NewTransaction(scope, ReadMode)
-	if err != nil {
-		return err
-	}
+type DatabaseInfo struct {
+	Name    string
+	Version int
+}
 
-	return fn(tx)
+// list the existing databases.
+func Databases() ([]DatabaseInfo, error) {
+	return databases(IndexedDB)
 }
 
-func (db *DB) Update(scope []string, fn func(tx *Transaction) error) error {
-	tx, err := db.NewTransaction(scope, ReadWriteMode)
+// list the existing databases of the factory.
+func databases(factory js.Value) ([]DatabaseInfo, error) {
+	// not every browser supports listing databases.
+	if factory.Get("databases").Type() != js.TypeFunction {
+		return nil, errors.New("listing databases is not supported")
+	}
+
+	res, err := awaitPromise(factory.Call("databases"))
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	infos := make([]DatabaseInfo, res.Length())
+
+	for i := range infos {
+		v := res.Index(i)
+
+		infos[i] = DatabaseInfo{
+			Name:    v.Get("name").String(),
+			Version: v.Get("version").Int(),
+		}
 	}
 
-	return fn(tx)
+	return infos, nil
 }
 
-// close the database.
-func (db *DB) Close() error {
-	db.value.Call("close")
+// run fn in a new goroutine, calling done with the result once it returns.
+//
+// every request waits for an event, which can only fire once the javascript event loop is free.
+// waiting inside a `js.FuncOf` callback, such as an event listener, blocks the event loop and deadlocks.
+// use this from a callback instead, done must not wait on a request either.
+// a transaction opened before the callback has likely committed, so open it within fn.
+func Async[T any](fn func() (T, error), done func(T, error)) {
+	go func() {
+		done(fn())
+	}()
+}
 
-	return nil
+// wait for a `IDBRequest` to either return and error or success message.
+// optionally pass an error channel.
+func await(v js.Value, errChan chan error) error {
+	return awaitContext(context.Background(), v, errChan)
 }
 
-func New(name string, version int, upgrade func(up *Upgrade) error) (*DB, error) {
-	errChan := make(chan error, 1)
+// wait for a `IDBRequest` like `await`, returning early if the context is done.
+func awaitContext(ctx context.Context, v js.Value, errChan chan error) error {
+	return awaitTimeout(ctx, v, errChan, AwaitTimeout)
+}
 
-	// open the database.
-	req := IndexedDB.Call("open", name, version)
+// wait for a `IDBRequest` like `awaitContext`, with a timeout other than `AwaitTimeout`.
+func awaitTimeout(ctx context.Context, v js.Value, errChan chan error, timeout time.Duration) error {
+	if errChan == nil {
+		errChan = make(chan error, 1)
+	}
 
-	// handle the upgrade event.
-	listen(req, "onupgradeneeded", func(v js.Value) {
-		// get the database connection.
-		val := v.Get("target").Get("result")
+	// remove the handlers even if we stop waiting early, such as after a timeout.
+	release := listenResult(v, errChan)
+	defer release()
 
-		// create a upgrade.
-		up := &Upgrade{
-			value: val,
+	return wait(ctx, transactionOf(v), errChan, timeout)
+}
+
+// wait for an error or success message, returning early if the transaction finishes without one.
+func wait(ctx context.Context, tx *Transaction, errChan chan error, timeout time.Duration) error {
+	// a nil channel never receives, so we wait forever if there is no timeout.
+	var expired <-chan time.Time
+
+	if timeout > 0 {
+		expired = time.After(timeout)
+	}
+
+	// a nil channel never receives, so we only watch the transaction if there is one.
+	var done <-chan struct{}
+
+	if tx != nil {
+		done = tx.done
+	}
+
+	// wait for either the error or success message.
+	select {
+	case err := <-errChan:
+		return err
+
+	case <-done:
+		// the message may have arrived just before the transaction finished.
+		select {
+		case err := <-errChan:
+			return err
+
+		default:
 		}
 
-		// call the upgrade event.
-		err := upgrade(up)
-		if err != nil {
-			errChan <- err
+		// the transaction aborted before the request finished.
+		err := tx.Err()
+		if err == nil {
+			err = ErrTransactionInactive
 		}
+
+		return err
+
+	case <-expired:
+		return ErrTimeout
+
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// wait for a promise to either resolve or reject, returning the resolved value.
+func awaitPromise(p js.Value) (js.Value, error) {
+	type result struct {
+		value js.Value
+		err   error
+	}
+
+	resChan := make(chan result, 1)
+
+	var then, catch js.Func
+
+	// release both handlers as only one is ever called.
+	release := func() {
+		then.Release()
+		catch.Release()
+	}
+
+	then = js.FuncOf(func(this js.Value, args []js.Value) any {
+		resChan <- result{value: args[0]}
+		release()
+
+		return nil
+	})
+
+	catch = js.FuncOf(func(this js.Value, args []js.Value) any {
+		resChan <- result{err: wrapError(args[0])}
+		release()
+
+		return nil
 	})
 
-	err := await(req, errChan)
+	p.Call("then", then, catch)
+
+	// a nil channel never receives, so we wait forever if there is no timeout.
+	var timeout <-chan time.Time
+
+	if AwaitTimeout > 0 {
+		timeout = time.After(AwaitTimeout)
+	}
+
+	select {
+	case res := <-resChan:
+		return res.value, res.err
+
+	case <-timeout:
+		return js.Value{}, ErrTimeout
+	}
+}
+
+// make a `count` request on a store or index, the query is a key, range or undefined to count every record.
+func count(ctx context.Context, v js.Value, query js.Value) (int, error) {
+	req, err := call(v, "count", query)
+	if err != nil {
+		return 0, err
+	}
+
+	// wait for the request to complete.
+	err = awaitContext(ctx, req, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	return req.Get("result").Int(), nil
+}
+
+// make a `getAll` or `getAllKeys` request on a store or index.
+func getAll(v js.Value, method string, query any, limit int) ([]js.Value, error) {
+	q, err := toOptionalQuery(query)
 	if err != nil {
 		return nil, err
 	}
 
-	// return the database connection.
-	return &DB{
-		value: req.Get("result"),
-	}, nil
+	count := js.Undefined()
+
+	// the count is optional, leave it undefined to get everything.
+	if limit > 0 {
+		count = js.ValueOf(limit)
+	}
+
+	req, err := call(v, method, q, count)
+	if err != nil {
+		return nil, err
+	}
+
+	// wait for the request to complete.
+	err = await(req, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return ToSlice(req.Get("result")), nil
 }
 
-// wait for a `IDBRequest` to either return and error or success message.
-// optionally pass an error channel.
-func await(v js.Value, errChan chan error) error {
-	if errChan == nil {
-		errChan = make(chan error, 1)
+// convert a javascript array to a slice, returning nil if the value isn't an array.
+func ToSlice(v js.Value) []js.Value {
+	if !Array.Call("isArray", v).Bool() {
+		return nil
 	}
 
-	// handle the error event.
-	listen(v, "onerror", func(v js.Value) {
-		// wrap and return the error event.
-		errChan <- wrapError(v)
-	})
+	s := make([]js.Value, v.Length())
 
-	// handle the success event.
-	listen(v, "onsuccess", func(v js.Value) {
-		errChan <- nil
+	for i := range s {
+		s[i] = v.Index(i)
+	}
+
+	return s
+}
+
+// convert a `DOMStringList` or an array of strings to a slice, returning nil if the value is neither.
+func StringList(v js.Value) []string {
+	// an array is indexed directly.
+	if Array.Call("isArray", v).Bool() {
+		list := make([]string, v.Length())
+
+		for i := range list {
+			list[i] = v.Index(i).String()
+		}
+
+		return list
+	}
+
+	if v.Type() != js.TypeObject || v.Get("item").Type() != js.TypeFunction {
+		return nil
+	}
+
+	list := make([]string, v.Length())
+
+	for i := range list {
+		list[i] = v.Call("item", i).String()
+	}
+
+	return list
+}
+
+// call a javascript method, returning a thrown exception as an error instead of panicking.
+func call(v js.Value, method string, args ...any) (res js.Value, err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		// only recover javascript exceptions.
+		jsErr, ok := r.(js.Error)
+		if !ok {
+			panic(r)
+		}
+
+		err = wrapError(jsErr.Value)
+	}()
+
+	return v.Call(method, args...), nil
+}
+
+// listen for every occurrence of an event, until the handler is released.
+func listenAll(v js.Value, event string, fn func(event js.Value)) js.Func {
+	h := js.FuncOf(func(this js.Value, args []js.Value) any {
+		// forward the event argument.
+		fn(args[0])
+
+		// return nothing.
+		return nil
 	})
 
-	// wait for either the error or success message.
-	// TODO: add timeout.
-	return <-errChan
+	// add the handler.
+	v.Call("addEventListener", event, h)
+
+	return h
 }
 
-// listen for an event.
-func listen(v js.Value, target string, fn func(event js.Value)) {
-	var h js.Func
+// listen for an event, the handler is released once it fires or release is called.
+func listen(v js.Value, target string, fn func(event js.Value)) (release func()) {
+	var (
+		h    js.Func
+		once sync.Once
+	)
+
+	release = func() {
+		once.Do(func() {
+			// don't remove a handler that has replaced ours.
+			if v.Get(target).Equal(h.Value) {
+				v.Set(target, js.Null())
+			}
+
+			h.Release()
+
+			listening.Add(-1)
+		})
+	}
 
 	// create the handler.
 	h = js.FuncOf(func(this js.Value, args []js.Value) any {
+		// remove the function, it may listen again.
+		release()
+
 		// forward the event argument.
 		fn(args[0])
 
-		// remove the function.
-		h.Release()
-
 		// return nothing.
 		return nil
 	})
 
+	listening.Add(1)
+
 	// set the handler.
 	v.Set(target, h)
+
+	return release
+}
+
+// the number of handlers from `listen` that haven't been released.
+var listening atomic.Int64
+
+// send the result of a request to the channel, release removes the handlers if it hasn't finished.
+func listenResult(req js.Value, errChan chan error) (release func()) {
+	// handle the error event.
+	releaseError := listen(req, "onerror", func(v js.Value) {
+		// wrap and return the error event.
+		errChan <- wrapError(v)
+	})
+
+	// handle the success event.
+	releaseSuccess := listen(req, "onsuccess", func(v js.Value) {
+		errChan <- nil
+	})
+
+	// a request only ever fires one of success or error, so the other is released too.
+	return func() {
+		releaseError()
+		releaseSuccess()
+	}
+}
+
+// an error from indexeddb, usually a `DOMException`.
+//
+// https://developer.mozilla.org/en-US/docs/Web/API/DOMException.
+type DBError struct {
+	// the name of the exception, such as "ConstraintError".
+	Name    string
+	Message string
+
+	// the original javascript error.
+	Value js.Value
+}
+
+func (e *DBError) Error() string {
+	if e.Message == "" {
+		return e.Name
+	}
+
+	return e.Name + ": " + e.Message
+}
+
+// match the sentinel error for the name of the exception.
+func (e *DBError) Is(target error) bool {
+	err, ok := domErrors[e.Name]
+
+	return ok && err == target
 }
 
 func wrapError(v js.Value) error {
+	// the error of an event is on its target, either the request or transaction.
+	if v.Type() == js.TypeObject {
+		if target := v.Get("target"); target.Type() == js.TypeObject {
+			if err := target.Get("error"); err.Type() == js.TypeObject {
+				v = err
+			}
+		}
+	}
+
+	// a `DOMException` has a name and a message.
+	if v.Type() == js.TypeObject && v.Get("name").Type() == js.TypeString {
+		msg := ""
+
+		if v.Get("message").Type() == js.TypeString {
+			msg = v.Get("message").String()
+		}
+
+		return &DBError{
+			Name:    v.Get("name").String(),
+			Message: msg,
+			Value:   v,
+		}
+	}
+
+	// an event without an error would otherwise be "[object Event]".
+	if v.Type() == js.TypeObject && v.Get("type").Type() == js.TypeString {
+		return fmt.Errorf("%s event without an error", v.Get("type").String())
+	}
+
 	// ensure we have method to convert to a string,
 	if v.Get("toString").IsNull() {
 		return errors.New("invalid javascript error")
@@ -2,10 +2,20 @@
 
 package indexeddb
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"strings"
+	"syscall/js"
+	"testing"
+	"time"
+)
 
 func TestBasic(t *testing.T) {
-	db, err := New("counter", 1, func(up *Upgrade) error {
+	db, err := New("counter", 1, func(up *Upgrade, oldVersion, newVersion int) error {
 		up.CreateStore("count")
 
 		return nil
@@ -43,8 +53,224 @@ func TestBasic(t *testing.T) {
 	}
 }
 
+// open a database with a single store named count, and a read write transaction on it.
+func openCount(t *testing.T, name string) (*Transaction, *Store) {
+	t.Helper()
+
+	db, err := New(name, 1, func(up *Upgrade, oldVersion, newVersion int) error {
+		up.NewStore("count", nil)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	tx, err := db.NewTransaction([]string{"count"}, ReadWriteMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tx, tx.Store("count")
+}
+
+func TestGetTyped(t *testing.T) {
+	tx, str := openCount(t, "get-typed")
+
+	err := str.Put("apples", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := GetInt(str, "apples")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 10 {
+		t.Fatalf("expected 10 but got %d", n)
+	}
+
+	_, err = GetString(str, "apples")
+	if !errors.Is(err, ErrInvalidType) {
+		t.Fatalf("expected ErrInvalidType but got %v", err)
+	}
+
+	_, err = GetInt(str, "pears")
+	if !errors.Is(err, ErrValueNotFound) {
+		t.Fatalf("expected ErrValueNotFound but got %v", err)
+	}
+
+	err = tx.Done()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIncrement(t *testing.T) {
+	tx, str := openCount(t, "increment")
+
+	err := str.Put("apples", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := str.Increment("apples", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 15 {
+		t.Fatalf("expected 15 but got %d", n)
+	}
+
+	n, err = str.Increment("apples", -5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 10 {
+		t.Fatalf("expected 10 but got %d", n)
+	}
+
+	// a missing key starts at 0.
+	n, err = str.Increment("oranges", -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != -1 {
+		t.Fatalf("expected -1 but got %d", n)
+	}
+
+	err = tx.Done()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPutResult(t *testing.T) {
+	tx, str := openCount(t, "put-result")
+
+	err := str.Put("horses", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	existed, err := str.PutResult("horses", 21)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !existed {
+		t.Fatal("expected horses to have existed")
+	}
+
+	existed, err = str.PutResult("cows", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if existed {
+		t.Fatal("expected cows to not have existed")
+	}
+
+	err = tx.Done()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetMany(t *testing.T) {
+	tx, str := openCount(t, "get-many")
+
+	err := str.Put("horses", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = str.Put("apples", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vs, err := str.GetMany([]any{"apples", "pears", "horses"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(vs) != 3 || vs[0].Int() != 10 || vs[1] != nil || vs[2].Int() != 20 {
+		t.Fatalf("expected [10 nil 20] but got %v", vs)
+	}
+
+	err = tx.Done()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHas(t *testing.T) {
+	tx, str := openCount(t, "has")
+
+	err := str.Put("apples", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	has, err := str.Has("apples")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !has {
+		t.Fatal("expected apples to exist")
+	}
+
+	has, err = str.Has("pears")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if has {
+		t.Fatal("expected pears to not exist")
+	}
+
+	err = tx.Done()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNullValue(t *testing.T) {
+	tx, str := openCount(t, "null-value")
+
+	// a nil value is stored as null, which is different to not being found.
+	err := str.Put("tombstone", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := str.Get("tombstone")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !v.IsNull() {
+		t.Fatalf("expected null but got %v", v)
+	}
+
+	err = tx.Done()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestIndex(t *testing.T) {
-	db, err := New("index", 1, func(up *Upgrade) error {
+	db, err := New("index", 1, func(up *Upgrade, oldVersion, newVersion int) error {
 		str := up.NewStore("people", &StoreConfig{
 			KeyPath: "age",
 		})
@@ -88,6 +314,175 @@ func TestIndex(t *testing.T) {
 		}
 	})
 
+	t.Run("get typed by name", func(t *testing.T) {
+		jim, err := GetFromIndex[person](str.Index("name"), "jim")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if jim.Age != 25 {
+			t.Fatalf("expected 25 got %d", jim.Age)
+		}
+
+		_, err = GetFromIndex[person](str.Index("name"), "bob")
+		if !errors.Is(err, ErrValueNotFound) {
+			t.Fatalf("expected ErrValueNotFound got %v", err)
+		}
+	})
+
+	t.Run("get key by name", func(t *testing.T) {
+		key, err := str.Index("name").GetKey("jim")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if key.Int() != 25 {
+			t.Fatalf("expected 25 got %d", key.Int())
+		}
+
+		_, err = str.Index("name").GetKey("bob")
+		if !errors.Is(err, ErrValueNotFound) {
+			t.Fatalf("expected ErrValueNotFound got %v", err)
+		}
+	})
+
+	t.Run("get all by name", func(t *testing.T) {
+		obj := Object.New()
+		obj.Set("age", 30)
+		obj.Set("name", "jim")
+
+		err = str.Add(nil, obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rng, err := Only("jim")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		jims, err := str.Index("name").GetAll(rng, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(jims) != 2 {
+			t.Fatalf("expected 2 got %d", len(jims))
+		}
+
+		// a plain key matches like `Only`.
+		jims, err = str.Index("name").GetAll("jim", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(jims) != 2 {
+			t.Fatalf("expected 2 got %d", len(jims))
+		}
+
+		_, err = str.Index("name").GetAll(math.NaN(), 0)
+		if !errors.Is(err, ErrKeyInvalid) {
+			t.Fatalf("expected ErrKeyInvalid got %v", err)
+		}
+
+		n, err := str.Index("name").Count(rng)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n != 2 {
+			t.Fatalf("expected 2 got %d", n)
+		}
+
+		keys, err := str.Index("name").GetAllKeys(rng, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(keys) != 1 || keys[0].Int() != 25 {
+			t.Fatalf("expected [25] got %v", keys)
+		}
+
+		n, err = str.Index("name").CountByKey("jim")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n != 2 {
+			t.Fatalf("expected 2 got %d", n)
+		}
+
+		ok, err := str.Index("name").Has("jim")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !ok {
+			t.Fatal("expected a person named jim")
+		}
+
+		ok, err = str.Index("name").Has("creed")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok {
+			t.Fatal("expected no person named creed")
+		}
+	})
+
+	t.Run("cursor by age", func(t *testing.T) {
+		cur, err := str.Index("age").OpenCursor(nil, Next)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var ages []int
+
+		for cur.Valid() {
+			if cur.KeyInt() != cur.PrimaryKeyInt() {
+				t.Fatal("expected the age index key to match the primary key")
+			}
+
+			ages = append(ages, cur.KeyInt())
+
+			err = cur.Continue(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if len(ages) != 2 || ages[0] != 25 || ages[1] != 30 {
+			t.Fatalf("expected 25 and 30 got %v", ages)
+		}
+	})
+
+	t.Run("cursor by name unique", func(t *testing.T) {
+		cur, err := str.Index("name").OpenCursor(nil, PrevUnique)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		n := 0
+
+		for cur.Valid() {
+			n++
+
+			if cur.KeyString() != "jim" || cur.PrimaryKeyInt() == 0 {
+				t.Fatalf("expected jim with an age got %s and %d", cur.KeyString(), cur.PrimaryKeyInt())
+			}
+
+			err = cur.Continue(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if n != 1 {
+			t.Fatalf("expected 1 unique name got %d", n)
+		}
+	})
+
 	t.Run("get by name", func(t *testing.T) {
 		jim, err := str.Index("name").Get("jim")
 		if err != nil {
@@ -100,4 +495,2497 @@ func TestIndex(t *testing.T) {
 			t.Fatalf("expected 25 got %d", age)
 		}
 	})
+
+	t.Run("finished transaction", func(t *testing.T) {
+		idx := str.Index("name")
+
+		err := tx.Done()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the browser throws instead of firing an error event.
+		_, err = idx.Get("jim")
+		if !errors.Is(err, ErrTransactionInactive) {
+			t.Fatalf("expected ErrTransactionInactive got %v", err)
+		}
+
+		_, err = idx.GetKey("jim")
+		if !errors.Is(err, ErrTransactionInactive) {
+			t.Fatalf("expected ErrTransactionInactive got %v", err)
+		}
+	})
+}
+
+func TestGetAllKeys(t *testing.T) {
+	db, err := New("keys", 1, func(up *Upgrade, oldVersion, newVersion int) error {
+		up.NewStore("letters", nil)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	tx, err := db.NewTransaction([]string{"letters"}, ReadWriteMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	str := tx.Store("letters")
+
+	for i, l := range []string{"a", "b", "c", "d"} {
+		err = str.Put(i, l)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rng, err := Bound(1, 2, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := str.GetAllKeys(rng, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := str.CountRange(rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 2 {
+		t.Fatalf("expected 2 got %d", n)
+	}
+
+	if len(keys) != 2 || keys[0].Int() != 1 || keys[1].Int() != 2 {
+		t.Fatalf("expected keys 1 and 2 got %v", keys)
+	}
+
+	vals, err := str.GetAll(nil, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(vals) != 3 || vals[2].String() != "c" {
+		t.Fatalf("expected 3 values ending in c got %v", vals)
+	}
+
+	err = str.Delete(rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err = str.Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 2 {
+		t.Fatalf("expected 2 got %d", n)
+	}
+}
+
+func TestCompoundKeyPath(t *testing.T) {
+	db, err := New("compound", 1, func(up *Upgrade, oldVersion, newVersion int) error {
+		str := up.NewStore("people", &StoreConfig{
+			KeyPaths: []string{"last", "first"},
+		})
+
+		str.CreateIndex("byAge", "age", nil)
+		str.CreateIndex("byFirstAndAge", "", &IndexConfig{
+			KeyPaths: []string{"first", "age"},
+		})
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	tx, err := db.NewTransaction([]string{"people"}, ReadWriteMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	str := tx.Store("people")
+
+	obj := Object.New()
+	obj.Set("first", "jim")
+	obj.Set("last", "smith")
+	obj.Set("age", 25)
+
+	err = str.Update(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := Array.New("smith", "jim")
+
+	jim, err := str.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	age := jim.Get("age").Int()
+
+	if age != 25 {
+		t.Fatalf("expected 25 got %d", age)
+	}
+
+	jim, err = str.Index("byAge").Get(25)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if jim.Get("first").String() != "jim" {
+		t.Fatalf("expected jim got %s", jim.Get("first"))
+	}
+
+	jim, err = str.Index("byFirstAndAge").Get([]any{"jim", 25})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if jim.Get("last").String() != "smith" {
+		t.Fatalf("expected smith got %s", jim.Get("last"))
+	}
+
+	jim, err = str.Get([]any{"smith", "jim"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if jim.Get("age").Int() != 25 {
+		t.Fatalf("expected 25 got %d", jim.Get("age").Int())
+	}
+
+	err = str.Delete([]string{"smith", "jim"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = str.Get([]any{"smith", "jim"})
+	if !errors.Is(err, ErrValueNotFound) {
+		t.Fatalf("expected ErrValueNotFound got %v", err)
+	}
+
+	_, err = str.Get([]any{"smith", nil})
+	if !errors.Is(err, ErrKeyInvalid) {
+		t.Fatalf("expected ErrKeyInvalid got %v", err)
+	}
+}
+
+func TestUniqueIndex(t *testing.T) {
+	db, err := New("unique", 1, func(up *Upgrade, oldVersion, newVersion int) error {
+		str := up.NewStore("users", &StoreConfig{
+			AutoIncrement: true,
+		})
+		str.CreateIndex("byEmail", "email", &IndexConfig{
+			Unique: true,
+		})
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	tx, err := db.NewTransaction([]string{"users"}, ReadWriteMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	str := tx.Store("users")
+
+	obj := Object.New()
+	obj.Set("email", "jim@example.com")
+
+	key, err := str.AddKey(nil, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jim, err := str.Index("byEmail").Get("jim@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	email := jim.Get("email").String()
+
+	if email != "jim@example.com" {
+		t.Fatalf("expected jim@example.com got %s", email)
+	}
+
+	if key.Int() != 1 {
+		t.Fatalf("expected generated key 1 got %d", key.Int())
+	}
+
+	// a duplicate email fails the request and the transaction, without panicking.
+	err = str.Add(nil, obj)
+	if !errors.Is(err, ErrConstraint) {
+		t.Fatalf("expected ErrConstraint got %v", err)
+	}
+
+	err = tx.Done()
+	if !errors.Is(err, ErrConstraint) {
+		t.Fatalf("expected transaction ErrConstraint got %v", err)
+	}
+
+	// a put under a new key is also a duplicate.
+	err = db.Update([]string{"users"}, func(tx *Transaction) error {
+		str := tx.Store("users")
+
+		err := str.Put(1, obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return str.Put(2, obj)
+	})
+	if !errors.Is(err, ErrConstraint) {
+		t.Fatalf("expected ErrConstraint got %v", err)
+	}
+}
+
+func TestContext(t *testing.T) {
+	db, err := New("context", 1, func(up *Upgrade, oldVersion, newVersion int) error {
+		up.NewStore("count", nil)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	tx, err := db.NewTransaction([]string{"count"}, ReadWriteMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	str := tx.Store("count")
+
+	err = str.PutContext(context.Background(), "horses", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = str.GetContext(ctx, "horses")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled got %v", err)
+	}
+}
+
+func TestCursor(t *testing.T) {
+	db, err := New("cursor", 1, func(up *Upgrade, oldVersion, newVersion int) error {
+		up.NewStore("numbers", nil)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	tx, err := db.NewTransaction([]string{"numbers"}, ReadWriteMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	str := tx.Store("numbers")
+
+	for i := 1; i <= 4; i++ {
+		err = str.Put(i, i)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cur, err := str.OpenCursor(nil, Next)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// double the even numbers and delete the odd numbers.
+	for cur.Valid() {
+		n := cur.Value().Int()
+
+		if n%2 == 0 {
+			err = cur.Update(n * 2)
+		} else {
+			err = cur.Delete()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = cur.Continue(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	vals, err := str.GetAll(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(vals) != 2 || vals[0].Int() != 4 || vals[1].Int() != 8 {
+		t.Fatalf("expected 4 and 8 got %v", vals)
+	}
+
+	for i := 5; i <= 10; i++ {
+		err = str.Put(i, i)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the keys are now 2, 4, 5, 6, 7, 8, 9, 10.
+	page, err := str.Page(nil, Prev, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(page) != 3 || page[0].Int() != 8 || page[2].Int() != 6 {
+		t.Fatalf("expected 8, 7 and 6 got %v", page)
+	}
+
+	cur, err = str.OpenKeyCursor(nil, Next)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cur.Key().Int() != 2 || !cur.Value().IsUndefined() {
+		t.Fatalf("expected key 2 without a value got %v and %v", cur.Key(), cur.Value())
+	}
+
+	var sum int
+
+	all, iterErr := str.All(nil, Prev)
+
+	for k, v := range all {
+		if k.Int() < 8 {
+			break
+		}
+
+		sum += v.Int()
+	}
+
+	if iterErr() != nil {
+		t.Fatal(iterErr())
+	}
+
+	// the values are 8, 9 and 10 from the highest keys.
+	if sum != 27 {
+		t.Fatalf("expected 27 got %d", sum)
+	}
+
+	var keys []int
+
+	err = str.Each(nil, Next, func(key, value js.Value) error {
+		if key.Int() > 5 {
+			return StopIteration
+		}
+
+		keys = append(keys, key.Int())
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keys) != 3 || keys[0] != 2 || keys[2] != 5 {
+		t.Fatalf("expected [2 4 5] got %v", keys)
+	}
+
+	errStop := errors.New("stop")
+
+	err = str.Each(nil, Next, func(key, value js.Value) error {
+		return errStop
+	})
+	if err != errStop {
+		t.Fatalf("expected the callback error got %v", err)
+	}
+
+	// seek forward to a key.
+	err = cur.Continue(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cur.Key().Int() != 7 {
+		t.Fatalf("expected key 7 got %v", cur.Key())
+	}
+
+	err = cur.Continue(3)
+	if !errors.Is(err, ErrData) {
+		t.Fatalf("expected ErrData got %v", err)
+	}
+
+	err = cur.Advance(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cur.Key().Int() != 9 {
+		t.Fatalf("expected key 9 got %v", cur.Key())
+	}
+
+	err = cur.Advance(0)
+	if err == nil {
+		t.Fatal("expected advancing by 0 to fail")
+	}
+
+	tx, err = db.NewTransaction([]string{"numbers"}, ReadMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ro := tx.Store("numbers")
+
+	cur, err = ro.OpenCursor(nil, Next)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cur.Delete()
+	if !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected ErrReadOnly got %v", err)
+	}
+
+	err = tx.Done()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the browser throws once the transaction has finished.
+	err = cur.Advance(1)
+	if !errors.Is(err, ErrTransactionInactive) {
+		t.Fatalf("expected ErrTransactionInactive got %v", err)
+	}
+
+	all, iterErr = ro.All(nil, Next)
+
+	for range all {
+		t.Fatal("expected no records from a finished transaction")
+	}
+
+	if !errors.Is(iterErr(), ErrTransactionInactive) {
+		t.Fatalf("expected ErrTransactionInactive got %v", iterErr())
+	}
+}
+
+func TestBatch(t *testing.T) {
+	db, err := New("batch", 1, func(up *Upgrade, oldVersion, newVersion int) error {
+		up.NewStore("count", nil)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	tx, err := db.NewTransactionWithConfig([]string{"count"}, ReadWriteMode, &TransactionConfig{
+		Durability: RelaxedDurability,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	str := tx.Store("count")
+	b := str.Batch()
+
+	if tx.value.Get("durability").String() != "relaxed" {
+		t.Fatal("expected relaxed durability")
+	}
+
+	for i := 0; i < 10; i++ {
+		err = b.Put(i, i*10)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = b.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := str.Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 10 {
+		t.Fatalf("expected 10 got %d", n)
+	}
+
+	err = str.PutAll(map[any]any{
+		"horses": 20,
+		"apples": 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := str.Get("apples")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v.Int() != 10 {
+		t.Fatalf("expected 10 got %d", v.Int())
+	}
+
+	for i := 0; i < 5; i++ {
+		err = b.Delete(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = b.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err = str.Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 7 {
+		t.Fatalf("expected 7 got %d", n)
+	}
+
+	// replace the contents of the store.
+	err = b.Clear()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = b.Put("pears", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = b.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err = str.Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 1 {
+		t.Fatalf("expected 1 got %d", n)
+	}
+
+	err = b.Put("plums", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = b.Add("pears", 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the error should say which operation failed.
+	err = b.Wait()
+	if !errors.Is(err, ErrConstraint) || !strings.Contains(err.Error(), "batch add #1") {
+		t.Fatalf("expected a constraint error for add #1 got %v", err)
+	}
+}
+
+type person struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func TestTypedStore(t *testing.T) {
+	db, err := New("typed", 1, func(up *Upgrade, oldVersion, newVersion int) error {
+		up.NewStore("people", &StoreConfig{
+			KeyPath: "name",
+		})
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	tx, err := db.NewTransaction([]string{"people"}, ReadWriteMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	str := NewTypedStore[person](tx.Store("people"))
+
+	err = str.Put(nil, person{Name: "jim", Age: 25})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jim, err := str.Get("jim")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if jim.Age != 25 {
+		t.Fatalf("expected 25 got %d", jim.Age)
+	}
+
+	_, err = str.Get("bob")
+	if !errors.Is(err, ErrValueNotFound) {
+		t.Fatalf("expected ErrValueNotFound got %v", err)
+	}
+
+	// the json tags are followed.
+	tagged := NewTypedStore[employee](tx.Store("people"))
+
+	err = tagged.Put(nil, employee{FullName: "pam", Name: "pam", Desk: 4, secret: "art"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := tx.Store("people").Get("pam")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if raw.Get("full_name").String() != "pam" || !raw.Get("Desk").IsUndefined() || !raw.Get("title").IsUndefined() {
+		t.Fatalf("expected the fields to follow their tags got %s", JSON.Call("stringify", *raw))
+	}
+
+	pam, err := tagged.Get("pam")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pam.FullName != "pam" || pam.Desk != 0 || pam.secret != "" {
+		t.Fatalf("expected pam got %+v", pam)
+	}
+}
+
+type employee struct {
+	FullName string `json:"full_name"`
+	Name     string `json:"name"`
+	Title    string `json:"title,omitempty"`
+	Desk     int    `json:"-"`
+	secret   string
+}
+
+type lineItem struct {
+	SKU      string `json:"sku"`
+	Quantity int    `json:"quantity,omitempty"`
+}
+
+type order struct {
+	ID       int        `json:"id"`
+	Customer person     `json:"customer"`
+	Items    []lineItem `json:"items"`
+	Note     *string    `json:"note"`
+	internal bool
+}
+
+func TestMarshal(t *testing.T) {
+	db, err := New("marshal", 1, func(up *Upgrade, oldVersion, newVersion int) error {
+		up.NewStore("orders", &StoreConfig{
+			KeyPath: "id",
+		})
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	tx, err := db.NewTransaction([]string{"orders"}, ReadWriteMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	str := tx.Store("orders")
+
+	err = str.Insert(order{
+		ID:       1,
+		Customer: person{Name: "jim", Age: 25},
+		Items:    []lineItem{{SKU: "apple", Quantity: 2}, {SKU: "pear"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the key is taken from the value.
+	err = str.Put(2, order{ID: 2})
+	if !errors.Is(err, ErrKeyInvalid) {
+		t.Fatalf("expected ErrKeyInvalid got %v", err)
+	}
+
+	v, err := str.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nm := v.Get("customer").Get("name").String()
+
+	if nm != "jim" {
+		t.Fatalf("expected jim got %s", nm)
+	}
+
+	items := v.Get("items")
+
+	if items.Length() != 2 || items.Index(0).Get("quantity").Int() != 2 {
+		t.Fatalf("expected 2 items with a quantity of 2 got %v", items)
+	}
+
+	if !items.Index(1).Get("quantity").IsUndefined() {
+		t.Fatal("expected empty quantity to be omitted")
+	}
+
+	if !v.Get("note").IsNull() {
+		t.Fatal("expected nil note to be null")
+	}
+
+	if !v.Get("internal").IsUndefined() {
+		t.Fatal("expected unexported field to be skipped")
+	}
+
+	var o order
+
+	err = Unmarshal(*v, &o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if o.Customer.Name != "jim" || len(o.Items) != 2 || o.Items[0].Quantity != 2 || o.Note != nil {
+		t.Fatalf("unexpected order %+v", o)
+	}
+
+	err = Unmarshal(v.Get("customer").Get("name"), &o.ID)
+	if !errors.Is(err, ErrInvalidType) {
+		t.Fatalf("expected ErrInvalidType got %v", err)
+	}
+
+	grid, err := Marshal([][]int{{1, 2}, {3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if grid.Length() != 2 || grid.Index(0).Index(1).Int() != 2 || grid.Index(1).Index(0).Int() != 3 {
+		t.Fatalf("expected nested arrays got %v", grid)
+	}
+
+	var point [3]float64
+
+	pv, err := Marshal([2]float64{1.5, 2.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Unmarshal(pv, &point)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if point != [3]float64{1.5, 2.5, 0} {
+		t.Fatalf("expected [1.5 2.5 0] got %v", point)
+	}
+
+	// the structured clone algorithm can't copy these, even nested in a struct.
+	for _, x := range []any{func() {}, make(chan int), complex(1, 2), struct{ Done chan bool }{}} {
+		_, err = Marshal(x)
+		if !errors.Is(err, ErrInvalidType) {
+			t.Fatalf("expected ErrInvalidType for %T got %v", x, err)
+		}
+	}
+
+	err = str.Put(nil, func() {})
+	if !errors.Is(err, ErrValueInvalid) {
+		t.Fatalf("expected ErrValueInvalid got %v", err)
+	}
+}
+
+// stores every value wrapped in an object.
+type wrapCodec struct{}
+
+func (wrapCodec) Marshal(x any) (js.Value, error) {
+	return Marshal(map[string]any{"wrapped": x})
+}
+
+func (wrapCodec) Unmarshal(v js.Value, dst any) error {
+	return Unmarshal(v.Get("wrapped"), dst)
+}
+
+func TestCodec(t *testing.T) {
+	Codec = wrapCodec{}
+	defer func() { Codec = reflectCodec{} }()
+
+	db, err := New("codec", 1, func(up *Upgrade, oldVersion, newVersion int) error {
+		up.NewStore("counts", nil)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	err = db.Update([]string{"counts"}, func(tx *Transaction) error {
+		str := tx.Store("counts")
+
+		err := str.Put("apples", 5)
+		if err != nil {
+			return err
+		}
+
+		v, err := str.Get("apples")
+		if err != nil {
+			return err
+		}
+
+		if v.Get("wrapped").Int() != 5 {
+			t.Fatalf("expected the value to be wrapped got %s", JSON.Call("stringify", *v))
+		}
+
+		n, err := GetInt(str, "apples")
+		if err != nil {
+			return err
+		}
+
+		if n != 5 {
+			t.Fatalf("expected 5 got %d", n)
+		}
+
+		typed := NewTypedStore[int](str)
+
+		err = typed.Put("pears", 3)
+		if err != nil {
+			return err
+		}
+
+		ns, err := typed.GetAll(nil, 0)
+		if err != nil {
+			return err
+		}
+
+		if len(ns) != 2 || ns[0] != 5 || ns[1] != 3 {
+			t.Fatalf("expected [5 3] from the typed store got %v", ns)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBytes(t *testing.T) {
+	db, err := New("bytes", 1, func(up *Upgrade, oldVersion, newVersion int) error {
+		up.NewStore("blobs", nil)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	tx, err := db.NewTransaction([]string{"blobs"}, ReadWriteMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	str := tx.Store("blobs")
+
+	err = str.Put("thumbnail", []byte{0xde, 0xad, 0xbe, 0xef})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := str.Get("thumbnail")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b []byte
+
+	err = Unmarshal(*v, &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "\xde\xad\xbe\xef" {
+		t.Fatalf("expected deadbeef got %x", b)
+	}
+
+	b, err = Bytes(v.Get("buffer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "\xde\xad\xbe\xef" {
+		t.Fatalf("expected deadbeef got %x", b)
+	}
+
+	_, err = Bytes(js.ValueOf("deadbeef"))
+	if !errors.Is(err, ErrInvalidType) {
+		t.Fatalf("expected ErrInvalidType got %v", err)
+	}
+}
+
+type event struct {
+	Name string    `json:"name"`
+	At   time.Time `json:"at"`
+}
+
+func TestTime(t *testing.T) {
+	db, err := New("time", 1, func(up *Upgrade, oldVersion, newVersion int) error {
+		up.NewStore("events", &StoreConfig{
+			KeyPath: "at",
+		})
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	tx, err := db.NewTransaction([]string{"events"}, ReadWriteMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	str := tx.Store("events")
+
+	now := time.UnixMilli(time.Now().UnixMilli())
+
+	for i, nm := range []string{"recent", "old"} {
+		err = str.Put(nil, event{Name: nm, At: now.Add(time.Duration(i) * -30 * 24 * time.Hour)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rng, err := LowerBound(now.Add(-7*24*time.Hour), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vals, err := str.GetAll(rng, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(vals) != 1 {
+		t.Fatalf("expected 1 event in the last week got %d", len(vals))
+	}
+
+	var e event
+
+	err = Unmarshal(vals[0], &e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Name != "recent" || !e.At.Equal(now) {
+		t.Fatalf("expected recent at %s got %+v", now, e)
+	}
+}
+
+type snowflake struct {
+	ID    int64  `json:"id"`
+	Flags uint64 `json:"flags"`
+}
+
+func TestLargeIntegers(t *testing.T) {
+	db, err := New("large", 1, func(up *Upgrade, oldVersion, newVersion int) error {
+		up.NewStore("ids", nil)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	tx, err := db.NewTransaction([]string{"ids"}, ReadWriteMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	str := tx.Store("ids")
+
+	want := snowflake{ID: 1<<62 + 1, Flags: 1<<64 - 1}
+
+	err = str.Put("post", want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := str.Get("post")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got snowflake
+
+	err = Unmarshal(*v, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != want {
+		t.Fatalf("expected %+v got %+v", want, got)
+	}
+
+	// a typed store keeps the precision too.
+	typed := NewTypedStore[snowflake](str)
+
+	got, err = typed.Get("post")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != want {
+		t.Fatalf("expected %+v from the typed store got %+v", want, got)
+	}
+
+	err = str.Put(int64(1<<62), "too large")
+	if !errors.Is(err, ErrKeyInvalid) {
+		t.Fatalf("expected ErrKeyInvalid got %v", err)
+	}
+
+	_, err = str.Get(math.NaN())
+	if !errors.Is(err, ErrKeyInvalid) {
+		t.Fatalf("expected ErrKeyInvalid got %v", err)
+	}
+
+	// infinity sorts after every other number.
+	err = str.Put(math.Inf(1), "last")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err = str.Get(math.Inf(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v.String() != "last" {
+		t.Fatalf("expected last got %s", v)
+	}
+
+	// float32 keys are widened the same way every time.
+	err = str.Put(float32(0.1), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = str.Get(float32(0.1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = str.Get(float64(float32(0.1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// pointers are dereferenced, but null is never a key.
+	id := "ptr"
+
+	err = str.Put(&id, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = str.Get("ptr")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var nilID *string
+
+	_, err = str.Get(nilID)
+	if !errors.Is(err, ErrKeyInvalid) {
+		t.Fatalf("expected ErrKeyInvalid got %v", err)
+	}
+}
+
+func TestDBError(t *testing.T) {
+	_, err := Bound(2, 1, false, false)
+
+	var dbErr *DBError
+
+	if !errors.As(err, &dbErr) {
+		t.Fatalf("expected a DBError got %v", err)
+	}
+
+	if dbErr.Name != "DataError" || !errors.Is(err, ErrData) {
+		t.Fatalf("expected a DataError got %s", dbErr.Name)
+	}
+
+	// the error of an event is read from its target.
+	exc := Object.New()
+	exc.Set("name", "ConstraintError")
+	exc.Set("message", "key already exists")
+
+	target := Object.New()
+	target.Set("error", exc)
+
+	event := Object.New()
+	event.Set("type", "error")
+	event.Set("target", target)
+
+	err = wrapError(event)
+	if !errors.Is(err, ErrConstraint) || !strings.Contains(err.Error(), "key already exists") {
+		t.Fatalf("expected a ConstraintError got %v", err)
+	}
+
+	target.Set("error", js.Null())
+
+	err = wrapError(event)
+	if err.Error() != "error event without an error" {
+		t.Fatalf("expected an event error got %v", err)
+	}
+}
+
+func TestAbort(t *testing.T) {
+	db, err := New("abort", 1, func(up *Upgrade, oldVersion, newVersion int) error {
+		up.NewStore("count", nil)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	tx, err := db.NewTransaction([]string{"count"}, ReadWriteMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	str := tx.Store("count")
+
+	err = str.Put("horses", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tx.Abort()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tx.Done()
+	if !errors.Is(err, ErrAborted) {
+		t.Fatalf("expected ErrAborted got %v", err)
+	}
+
+	// waiting on a request that never finishes should return once the transaction aborts.
+	err = wait(context.Background(), tx, make(chan error, 1), AwaitTimeout)
+	if !errors.Is(err, ErrAborted) {
+		t.Fatalf("expected ErrAborted got %v", err)
+	}
+
+	// the store should fail cleanly instead of calling the dead transaction.
+	err = str.Put("apples", 10)
+	if !errors.Is(err, ErrAborted) {
+		t.Fatalf("expected ErrAborted got %v", err)
+	}
+
+	tx, err = db.NewTransaction([]string{"count"}, ReadMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := tx.Store("count").Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 0 {
+		t.Fatalf("expected the put to be rolled back got %d records", n)
+	}
+
+	errStop := errors.New("stop")
+
+	err = db.Update([]string{"count"}, func(tx *Transaction) error {
+		err := tx.Store("count").Put("horses", 20)
+		if err != nil {
+			return err
+		}
+
+		return errStop
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("expected errStop got %v", err)
+	}
+
+	err = db.Update([]string{"count"}, func(tx *Transaction) error {
+		return tx.Store("count").Put("apples", 10)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.View([]string{"count"}, func(tx *Transaction) error {
+		n, err = tx.Store("count").Count()
+
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 1 {
+		t.Fatalf("expected only the committed put got %d records", n)
+	}
+}
+
+func TestDeleteStore(t *testing.T) {
+	db, err := New("migrate", 1, func(up *Upgrade, oldVersion, newVersion int) error {
+		str := up.NewStore("people", nil)
+		str.NewIndex("name")
+		str.NewIndex("age")
+
+		up.NewStore("legacy", nil)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.Close()
+
+	db, err = New("migrate", 2, func(up *Upgrade, oldVersion, newVersion int) error {
+		if oldVersion != 1 || newVersion != 2 {
+			t.Errorf("expected an upgrade from 1 to 2 got %d to %d", oldVersion, newVersion)
+		}
+
+		up.DeleteStore("legacy")
+		up.DeleteIndex("people", "age")
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	names := db.StoreNames()
+
+	if len(names) != 1 || names[0] != "people" {
+		t.Fatalf("expected only the people store got %v", names)
+	}
+
+	tx, err := db.NewTransaction([]string{"people"}, ReadMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indexes := tx.Store("people").IndexNames()
+
+	if len(indexes) != 1 || indexes[0] != "name" {
+		t.Fatalf("expected only the name index got %v", indexes)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	// keep the connection open when asked to close it.
+	db, err := NewWithConfig("delete", 1, &OpenConfig{
+		OnVersionChange: func() {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Delete("delete")
+	if !errors.Is(err, ErrBlocked) {
+		t.Fatalf("expected ErrBlocked got %v", err)
+	}
+
+	db.Close()
+
+	err = Delete("delete")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDatabases(t *testing.T) {
+	db, err := New("listed", 3, func(up *Upgrade, oldVersion, newVersion int) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	if db.Name() != "listed" || db.Version() != 3 {
+		t.Fatalf("expected listed at version 3 got %s at %d", db.Name(), db.Version())
+	}
+
+	infos, err := Databases()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, info := range infos {
+		if info.Name == "listed" && info.Version == 3 {
+			return
+		}
+	}
+
+	t.Fatalf("expected the listed database got %v", infos)
+}
+
+func TestFactory(t *testing.T) {
+	var opened []string
+
+	// a factory that records every open before delegating to the real one.
+	open := js.FuncOf(func(this js.Value, args []js.Value) any {
+		opened = append(opened, args[0].String())
+
+		return IndexedDB.Call("open", args[0], args[1])
+	})
+
+	defer open.Release()
+
+	factory := Object.New()
+	factory.Set("open", open)
+
+	db, err := NewWithConfig("factory", 1, &OpenConfig{
+		Factory: factory,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(opened) != 1 || opened[0] != "factory" {
+		t.Fatalf("expected the factory to open the database got %v", opened)
+	}
+
+	db.Close()
+
+	db, err = New("factory", 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.Close()
+
+	// the existing version is listed by the factory, not the global.
+	list, err := Marshal([]map[string]any{{"name": "factory", "version": 9}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	databases := js.FuncOf(func(this js.Value, args []js.Value) any {
+		return js.Global().Get("Promise").Call("resolve", list)
+	})
+
+	defer databases.Release()
+
+	factory.Set("databases", databases)
+
+	_, err = NewWithConfig("factory", 1, &OpenConfig{
+		Factory: factory,
+	})
+	if !errors.Is(err, ErrVersionTooLow) || !strings.Contains(err.Error(), "existing version is 9") {
+		t.Fatalf("expected the version from the factory got %v", err)
+	}
+}
+
+func TestVersionChange(t *testing.T) {
+	changed := make(chan struct{}, 1)
+
+	var old *DB
+
+	old, err := NewWithConfig("tabs", 1, &OpenConfig{
+		Upgrade: func(up *Upgrade, oldVersion, newVersion int) error {
+			up.NewStore("count", nil)
+
+			return nil
+		},
+		OnVersionChange: func() {
+			changed <- struct{}{}
+
+			old.Close()
+		},
+		Timeout: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := New("tabs", 2, func(up *Upgrade, oldVersion, newVersion int) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	select {
+	case <-changed:
+	default:
+		t.Fatal("expected the old connection to be notified")
+	}
+
+	_, err = New("tabs", 1, nil)
+	if !errors.Is(err, ErrVersionTooLow) || !errors.Is(err, ErrVersion) {
+		t.Fatalf("expected ErrVersionTooLow got %v", err)
+	}
+
+	if !strings.Contains(err.Error(), "existing version is 2") {
+		t.Fatalf("expected the existing version in %q", err)
+	}
+
+	// a connection is closed by default when another connection upgrades.
+	closed, err := New("tabs-closed", 1, func(up *Upgrade, oldVersion, newVersion int) error {
+		up.NewStore("count", nil)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upgraded, err := New("tabs-closed", 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer upgraded.Close()
+
+	_, err = closed.NewTransaction([]string{"count"}, ReadMode)
+
+	var dbErr *DBError
+
+	if !errors.As(err, &dbErr) || dbErr.Name != "InvalidStateError" {
+		t.Fatalf("expected an InvalidStateError got %v", err)
+	}
+}
+
+func TestToSlice(t *testing.T) {
+	s := ToSlice(Array.New("a", "b"))
+
+	if len(s) != 2 || s[1].String() != "b" {
+		t.Fatalf("expected a and b got %v", s)
+	}
+
+	if ToSlice(Object.New()) != nil {
+		t.Fatal("expected nil for an object")
+	}
+
+	l := StringList(Array.New("a", "b"))
+
+	if len(l) != 2 || l[1] != "b" {
+		t.Fatalf("expected a and b got %v", l)
+	}
+
+	if StringList(Object.New()) != nil {
+		t.Fatal("expected nil for an object")
+	}
+}
+
+func TestBatchKeys(t *testing.T) {
+	db, err := New("batchkeys", 1, func(up *Upgrade, oldVersion, newVersion int) error {
+		up.NewStore("people", &StoreConfig{
+			AutoIncrement: true,
+		})
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	tx, err := db.NewTransaction([]string{"people"}, ReadWriteMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := tx.Store("people").Batch()
+
+	for _, nm := range []string{"jim", "bob", "sue"} {
+		err = b.Add(nil, person{Name: nm})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = b.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := b.Keys()
+
+	if len(keys) != 3 || keys[0].Int() != 1 || keys[2].Int() != 3 {
+		t.Fatalf("expected keys 1 to 3 got %v", keys)
+	}
+
+	last, err := tx.Store("people").Last()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nm := last.Get("name").String()
+
+	if nm != "sue" {
+		t.Fatalf("expected sue got %s", nm)
+	}
+
+	rng, err := UpperBound(1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tx.Store("people").DeleteAll(rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := tx.Store("people").Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 2 {
+		t.Fatalf("expected 2 got %d", n)
+	}
+
+	err = tx.Store("people").DeleteAll(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the key generator is kept.
+	key, err := tx.Store("people").AddKey(nil, person{Name: "tom"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key.Int() != 4 {
+		t.Fatalf("expected key 4 got %v", key)
+	}
+
+	key, err = tx.Store("people").PutKey(nil, person{Name: "ann"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key.Int() != 5 {
+		t.Fatalf("expected key 5 got %v", key)
+	}
+
+	n, err = tx.Store("people").Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 2 {
+		t.Fatalf("expected 2 got %d", n)
+	}
+}
+
+func TestExport(t *testing.T) {
+	db, err := New("export", 1, func(up *Upgrade, oldVersion, newVersion int) error {
+		up.NewStore("count", nil)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	tx, err := db.NewTransaction([]string{"count"}, ReadWriteMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	str := tx.Store("count")
+
+	err = str.Put("apples", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = str.Put("horses", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := str.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `[{"key":"apples","value":10},{"key":"horses","value":20}]`
+
+	if string(data) != want {
+		t.Fatalf("expected %s got %s", want, data)
+	}
+
+	err = str.Clear()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing is imported if any record is malformed.
+	err = str.Import([]byte(`[{"key":"pears","value":5},{"value":6}]`))
+	if err == nil {
+		t.Fatal("expected an error for a record without a key")
+	}
+
+	ok, err := str.Has("pears")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok {
+		t.Fatal("expected the records before the malformed record not to be imported")
+	}
+
+	err = str.Import(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := str.Get("horses")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v.Int() != 20 {
+		t.Fatalf("expected 20 got %d", v.Int())
+	}
+
+	// these wouldn't import as the same type.
+	for _, x := range []any{time.UnixMilli(0), []byte("apples"), int64(1 << 60)} {
+		err = str.Put("other", x)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = str.Export()
+		if !errors.Is(err, ErrInvalidType) {
+			t.Fatalf("expected ErrInvalidType for %T got %v", x, err)
+		}
+	}
+
+	err = str.Delete("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tx.Done()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dump, err := db.Dump()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := dump["count"]
+
+	if len(records) != 2 || records[0].Key.String() != "apples" || records[1].Value.Int() != 20 {
+		t.Fatalf("expected apples and horses got %v", records)
+	}
+}
+
+func TestStorageEstimate(t *testing.T) {
+	usage, quota, err := StorageEstimate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if quota <= 0 || usage > quota {
+		t.Fatalf("expected usage within the quota got %d of %d", usage, quota)
+	}
+
+	_, err = PersistStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	db, err := New("wrap", 1, func(up *Upgrade, oldVersion, newVersion int) error {
+		up.NewStore("count", nil)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wrapped := WrapDB(db.JSValue())
+
+	defer wrapped.Close()
+
+	val := wrapped.JSValue().Call("transaction", "count", "readwrite")
+
+	tx := WrapTransaction(val)
+
+	str := WrapStore(tx.JSValue().Call("objectStore", "count"))
+
+	err = str.Put("horses", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tx.Done()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMap(t *testing.T) {
+	db, err := New("map", 1, func(up *Upgrade, oldVersion, newVersion int) error {
+		up.NewStore("records", nil)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	tx, err := db.NewTransaction([]string{"records"}, ReadWriteMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	str := tx.Store("records")
+
+	err = str.Put("jim", map[string]any{
+		"age": 25,
+		"address": map[string]string{
+			"city": "paris",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := str.Get("jim")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	city := v.Get("address").Get("city").String()
+
+	if city != "paris" {
+		t.Fatalf("expected paris got %s", city)
+	}
+
+	var m map[string]any
+
+	err = Unmarshal(*v, &m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m["age"] != float64(25) {
+		t.Fatalf("expected 25 got %v", m["age"])
+	}
+
+	var scores map[int]int
+
+	err = str.Put("scores", map[int]int{1: 10, 2: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err = str.Get("scores")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Unmarshal(*v, &scores)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if scores[2] != 20 {
+		t.Fatalf("expected 20 got %d", scores[2])
+	}
+}
+
+func TestListeners(t *testing.T) {
+	db, err := New("listeners", 1, func(up *Upgrade, oldVersion, newVersion int) error {
+		up.NewStore("count", nil)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	tx, err := db.NewTransaction([]string{"count"}, ReadWriteMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	str := tx.Store("count")
+
+	before := listening.Load()
+
+	for i := 0; i < 100; i++ {
+		err = str.Put(i, i)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	after := listening.Load()
+
+	// every handler should be removed once its request has finished.
+	if after != before {
+		t.Fatalf("expected %d listeners got %d", before, after)
+	}
+
+	// the handlers are removed when we stop waiting early too.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = str.PutContext(ctx, "cancelled", 1)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled got %v", err)
+	}
+
+	after = listening.Load()
+
+	if after != before {
+		t.Fatalf("expected %d listeners after cancelling got %d", before, after)
+	}
+
+	// opening an existing database never fires upgrade needed, deleting without other connections never fires blocked.
+	other, err := New("listeners-other", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other.Close()
+
+	other, err = New("listeners-other", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other.Close()
+
+	err = Delete("listeners-other")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after = listening.Load()
+
+	if after != before {
+		t.Fatalf("expected %d listeners after opening and deleting got %d", before, after)
+	}
+}
+
+type account struct {
+	ID   string `json:"id" idb:"key"`
+	Name string `json:"name"`
+}
+
+func TestStructKey(t *testing.T) {
+	db, err := New("struct-key", 1, func(up *Upgrade, oldVersion, newVersion int) error {
+		up.NewStore("accounts", nil)
+		up.NewStore("users", &StoreConfig{
+			KeyPath: "uid",
+		})
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	tx, err := db.NewTransaction([]string{"accounts", "users"}, ReadWriteMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the tagged field is used as an out-of-line key.
+	err = tx.Store("accounts").Put(nil, account{ID: "a1", Name: "jim"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := tx.Store("accounts").Get("a1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v.Get("name").String() != "jim" {
+		t.Fatalf("expected jim got %s", v.Get("name"))
+	}
+
+	// the tagged field is copied to the key path.
+	err = tx.Store("users").Insert(&account{ID: "b2", Name: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err = tx.Store("users").Get("b2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v.Get("name").String() != "bob" {
+		t.Fatalf("expected bob got %s", v.Get("name"))
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	db, err := New("retry", 1, func(up *Upgrade, oldVersion, newVersion int) error {
+		up.NewStore("count", nil)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	var calls int
+
+	err = db.WithRetry([]string{"count"}, ReadWriteMode, func(tx *Transaction) error {
+		calls++
+
+		err := tx.Store("count").Put("horses", calls)
+		if err != nil {
+			return err
+		}
+
+		// fail the first attempt with a transient error.
+		if calls == 1 {
+			return &DBError{Name: "UnknownError"}
+		}
+
+		return nil
+	}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 2 {
+		t.Fatalf("expected 2 calls got %d", calls)
+	}
+
+	calls = 0
+
+	err = db.WithRetry([]string{"count"}, ReadWriteMode, func(tx *Transaction) error {
+		calls++
+
+		return tx.Store("count").Add("horses", 0)
+	}, 3)
+	if !errors.Is(err, ErrConstraint) {
+		t.Fatalf("expected ErrConstraint got %v", err)
+	}
+
+	if calls != 1 {
+		t.Fatalf("expected 1 call got %d", calls)
+	}
+
+	err = db.View([]string{"count"}, func(tx *Transaction) error {
+		v, err := tx.Store("count").Get("horses")
+		if err != nil {
+			return err
+		}
+
+		if v.Int() != 2 {
+			t.Fatalf("expected 2 got %d", v.Int())
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+
+	prev := Logger
+	Logger = slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	}))
+
+	defer func() {
+		Logger = prev
+	}()
+
+	db, err := New("logger", 1, func(up *Upgrade, oldVersion, newVersion int) error {
+		up.NewStore("count", nil)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	err = db.Update([]string{"count"}, func(tx *Transaction) error {
+		str := tx.Store("count")
+
+		err := str.Add("horses", 20)
+		if err != nil {
+			return err
+		}
+
+		cur, err := str.OpenCursor(nil, Next)
+		if err != nil {
+			return err
+		}
+
+		err = cur.Continue(nil)
+		if err != nil {
+			return err
+		}
+
+		return str.Delete("horses")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, msg := range []string{"transaction open", "store add", "cursor open", "cursor continue", "store delete", "transaction complete"} {
+		if !strings.Contains(buf.String(), msg) {
+			t.Fatalf("expected %q to be logged", msg)
+		}
+	}
+
+	if !strings.Contains(buf.String(), "store=count") {
+		t.Fatal("expected the store name to be logged")
+	}
+}
+
+func TestAsync(t *testing.T) {
+	db, err := New("async", 1, func(up *Upgrade, oldVersion, newVersion int) error {
+		up.NewStore("count", nil)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	err = db.Update([]string{"count"}, func(tx *Transaction) error {
+		return tx.Store("count").Put("horses", 20)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := make(chan int, 1)
+
+	// simulate a javascript callback, which can't wait on the request itself.
+	cb := js.FuncOf(func(this js.Value, args []js.Value) any {
+		Async(func() (int, error) {
+			var n int
+
+			// open the transaction within fn, a transaction from outside would've committed by now.
+			err := db.View([]string{"count"}, func(tx *Transaction) error {
+				var err error
+
+				n, err = GetInt(tx.Store("count"), "horses")
+
+				return err
+			})
+
+			return n, err
+		}, func(n int, err error) {
+			if err != nil {
+				t.Error(err)
+			}
+
+			res <- n
+		})
+
+		return nil
+	})
+
+	defer cb.Release()
+
+	js.Global().Call("setTimeout", cb, 0)
+
+	n := <-res
+
+	if n != 20 {
+		t.Fatalf("expected 20 got %d", n)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	var ran []int
+
+	migrations := []Migration{
+		{Version: 1, Up: func(up *Upgrade) error {
+			ran = append(ran, 1)
+			up.NewStore("people", nil)
+
+			return nil
+		}},
+	}
+
+	db, err := Open("migrations", migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.Close()
+
+	// the caller's order doesn't matter.
+	migrations = append([]Migration{
+		{Version: 2, Up: func(up *Upgrade) error {
+			ran = append(ran, 2)
+			up.NewStore("pets", nil)
+
+			return nil
+		}},
+	}, migrations...)
+
+	db, err = Open("migrations", migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	if len(ran) != 2 || ran[0] != 1 || ran[1] != 2 {
+		t.Fatalf("expected each migration to run once in order got %v", ran)
+	}
+
+	if db.Version() != 2 || len(db.StoreNames()) != 2 {
+		t.Fatalf("expected version 2 with 2 stores got %d and %v", db.Version(), db.StoreNames())
+	}
+
+	tx, err := db.NewTransaction([]string{"people", "pets"}, ReadMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stores := tx.Stores()
+
+	if len(stores) != 2 || stores["pets"] == nil || stores["pets"].Name() != "pets" {
+		t.Fatalf("expected the people and pets stores got %v", stores)
+	}
+
+	_, err = Open("migrations", nil)
+	if err == nil {
+		t.Fatal("expected an error without migrations")
+	}
+}
+
+func TestFailedMigration(t *testing.T) {
+	migrations := []Migration{
+		{Version: 1, Up: func(up *Upgrade) error {
+			up.NewStore("people", nil)
+
+			return nil
+		}},
+	}
+
+	db, err := Open("failed-migration", migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.Close()
+
+	errMigration := errors.New("migration failed")
+
+	failing := append(migrations, Migration{Version: 2, Up: func(up *Upgrade) error {
+		up.NewStore("pets", nil)
+
+		return errMigration
+	}})
+
+	_, err = Open("failed-migration", failing)
+	if !errors.Is(err, errMigration) {
+		t.Fatalf("expected the migration error got %v", err)
+	}
+
+	// the failed upgrade is rolled back, so it runs again.
+	var ran bool
+
+	fixed := append(migrations, Migration{Version: 2, Up: func(up *Upgrade) error {
+		ran = true
+		up.NewStore("pets", nil)
+
+		return nil
+	}})
+
+	db, err = NewWithConfig("failed-migration", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if db.Version() != 1 || len(db.StoreNames()) != 1 {
+		t.Fatalf("expected version 1 with 1 store got %d and %v", db.Version(), db.StoreNames())
+	}
+
+	db.Close()
+
+	db, err = Open("failed-migration", fixed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	if !ran || db.Version() != 2 {
+		t.Fatalf("expected migration 2 to run again got version %d", db.Version())
+	}
+}
+
+func TestUpgradeStore(t *testing.T) {
+	migrations := []Migration{
+		{Version: 1, Up: func(up *Upgrade) error {
+			str := up.NewStore("people", nil)
+
+			return str.Put("jim", "jim halpert")
+		}},
+	}
+
+	db, err := Open("upgrade-store", migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.Close()
+
+	// rewrite the names into records.
+	migrations = append(migrations, Migration{Version: 2, Up: func(up *Upgrade) error {
+		str := up.Store("people")
+
+		c, err := str.OpenCursor(nil, Next)
+		if err != nil {
+			return err
+		}
+
+		for c.Valid() {
+			err = c.Update(map[string]any{"name": c.Value().String()})
+			if err != nil {
+				return err
+			}
+
+			err = c.Continue(nil)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}})
+
+	db, err = Open("upgrade-store", migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	err = db.View([]string{"people"}, func(tx *Transaction) error {
+		val, err := tx.Store("people").Get("jim")
+		if err != nil {
+			return err
+		}
+
+		if val.Get("name").String() != "jim halpert" {
+			t.Fatalf("expected the record to be migrated got %s", val.Get("name"))
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPutBatch(t *testing.T) {
+	db, err := New("put-batch", 1, func(up *Upgrade, oldVersion, newVersion int) error {
+		up.NewStore("rows", nil)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	entries := make([]Entry, 1000)
+
+	for i := range entries {
+		entries[i] = Entry{Key: i, Value: i * 2}
+	}
+
+	tx, err := db.NewTransaction([]string{"rows"}, ReadWriteMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tx.Store("rows").PutBatch(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an invalid entry rolls back every entry.
+	tx, err = db.NewTransaction([]string{"rows"}, ReadWriteMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tx.Store("rows").PutBatch([]Entry{{Key: 1000, Value: 1}, {Key: math.NaN(), Value: 2}})
+	if !errors.Is(err, ErrKeyInvalid) {
+		t.Fatalf("expected ErrKeyInvalid got %v", err)
+	}
+
+	err = db.View([]string{"rows"}, func(tx *Transaction) error {
+		n, err := tx.Store("rows").Count()
+		if err != nil {
+			return err
+		}
+
+		if n != 1000 {
+			t.Fatalf("expected 1000 got %d", n)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAwaitedOperations(t *testing.T) {
+	db, err := New("awaited", 1, func(up *Upgrade, oldVersion, newVersion int) error {
+		up.NewStore("count", nil)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	// each request is made while handling the previous one, so the transaction stays active.
+	err = db.Update([]string{"count"}, func(tx *Transaction) error {
+		str := tx.Store("count")
+
+		err := str.Put("horses", 20)
+		if err != nil {
+			return err
+		}
+
+		n, err := GetInt(str, "horses")
+		if err != nil {
+			return err
+		}
+
+		return str.Put("horses", n+1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// waiting for the transaction to commit within `Update` doesn't time out.
+	err = db.Update([]string{"count"}, func(tx *Transaction) error {
+		return tx.Store("count").PutBatch([]Entry{{Key: "apples", Value: 10}, {Key: "pears", Value: 5}})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.View([]string{"count"}, func(tx *Transaction) error {
+		str := tx.Store("count")
+
+		n, err := str.Count()
+		if err != nil {
+			return err
+		}
+
+		if n != 3 {
+			t.Fatalf("expected 3 records got %d", n)
+		}
+
+		horses, err := GetInt(str, "horses")
+		if err != nil {
+			return err
+		}
+
+		if horses != 21 {
+			t.Fatalf("expected 21 got %d", horses)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
 }
@@ -0,0 +1,111 @@
+//go:build js && wasm
+
+package indexeddb
+
+import (
+	"errors"
+	"syscall/js"
+)
+
+var keyRange = js.Global().Get("IDBKeyRange")
+
+// a key range is a continuous interval over keys, used to query a store or index.
+//
+// https://developer.mozilla.org/en-US/docs/Web/API/IDBKeyRange.
+type KeyRange struct {
+	value js.Value
+}
+
+// create a key range containing a single key.
+func Only(key any) (*KeyRange, error) {
+	return newKeyRange("only", key)
+}
+
+// create a key range with only a lower bound.
+// if open is true the bound itself is excluded.
+func LowerBound(key any, open bool) (*KeyRange, error) {
+	return newKeyRange("lowerBound", key, open)
+}
+
+// create a key range with only an upper bound.
+// if open is true the bound itself is excluded.
+func UpperBound(key any, open bool) (*KeyRange, error) {
+	return newKeyRange("upperBound", key, open)
+}
+
+// create a key range with both a lower and upper bound.
+func Bound(lower, upper any, lowerOpen, upperOpen bool) (*KeyRange, error) {
+	l, err := toKey(lower)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := toKey(upper)
+	if err != nil {
+		return nil, err
+	}
+
+	// the browser throws if the lower bound is greater than the upper bound.
+	val, err := call(keyRange, "bound", l, u, lowerOpen, upperOpen)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyRange{
+		value: val,
+	}, nil
+}
+
+func newKeyRange(method string, key any, args ...any) (*KeyRange, error) {
+	k, err := toKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	val, err := call(keyRange, method, append([]any{k}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyRange{
+		value: val,
+	}, nil
+}
+
+// validate and convert either a key or a `*KeyRange` to javascript.
+func toQuery(x any) (js.Value, error) {
+	rng, ok := x.(*KeyRange)
+	if !ok {
+		return toKey(x)
+	}
+
+	// a nil range would match everything, which is never intended for a key.
+	if rng == nil {
+		return js.Value{}, errors.Join(ErrKeyInvalid, errors.New("key range is nil"))
+	}
+
+	return rng.value, nil
+}
+
+// like `toQuery`, but nil or a nil `*KeyRange` is undefined, which matches every key.
+func toOptionalQuery(x any) (js.Value, error) {
+	if x == nil {
+		return js.Undefined(), nil
+	}
+
+	if rng, ok := x.(*KeyRange); ok {
+		return rng.query(), nil
+	}
+
+	return toKey(x)
+}
+
+// query returns the javascript value to pass to a request.
+// a nil key range is undefined, which matches every key.
+func (r *KeyRange) query() js.Value {
+	if r == nil {
+		return js.Undefined()
+	}
+
+	return r.value
+}
@@ -0,0 +1,771 @@
+//go:build js && wasm
+
+package indexeddb
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"syscall/js"
+	"time"
+)
+
+var (
+	jsValueType = reflect.TypeOf(js.Value{})
+	timeType    = reflect.TypeOf(time.Time{})
+
+	jsString = js.Global().Get("String")
+)
+
+// the largest integer a javascript number can represent exactly, `Number.MAX_SAFE_INTEGER`.
+const maxSafeInteger = 1<<53 - 1
+
+// converts values when they're stored and read, keys are always converted with `Marshal`.
+type ValueCodec interface {
+	Marshal(x any) (js.Value, error)
+	Unmarshal(v js.Value, dst any) error
+}
+
+// the codec used for values, replace it to customize how values are stored.
+var Codec ValueCodec = reflectCodec{}
+
+// the default codec, using `Marshal` and `Unmarshal`.
+type reflectCodec struct{}
+
+func (reflectCodec) Marshal(x any) (js.Value, error) {
+	return Marshal(x)
+}
+
+func (reflectCodec) Unmarshal(v js.Value, dst any) error {
+	return Unmarshal(v, dst)
+}
+
+// convert a Go value to a javascript value that can be stored.
+// structs become objects of their exported fields, honoring `json` tags, maps become objects and slices become arrays.
+// byte slices become a `Uint8Array` and times become a `Date`.
+// integers outside the safe range of a javascript number become a `BigInt`.
+// nil becomes null, which can be stored as a value but not used as a key.
+func Marshal(x any) (js.Value, error) {
+	if x == nil {
+		return js.Null(), nil
+	}
+
+	return marshal(reflect.ValueOf(x))
+}
+
+func marshal(v reflect.Value) (js.Value, error) {
+	// javascript values are passed through as is.
+	if v.Type() == jsValueType {
+		return v.Interface().(js.Value), nil
+	}
+
+	// times are stored as a date, so they can be indexed and compared.
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time)
+
+		return Date.New(float64(t.UnixMilli())), nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return js.ValueOf(v.String()), nil
+
+	case reflect.Bool:
+		return js.ValueOf(v.Bool()), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := v.Int()
+
+		// a number would silently lose precision.
+		if n > maxSafeInteger || n < -maxSafeInteger {
+			return BigInt.Invoke(strconv.FormatInt(n, 10)), nil
+		}
+
+		return js.ValueOf(n), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n := v.Uint()
+
+		// a number would silently lose precision.
+		if n > maxSafeInteger {
+			return BigInt.Invoke(strconv.FormatUint(n, 10)), nil
+		}
+
+		return js.ValueOf(n), nil
+
+	// a float32 is widened exactly, so the same float32 always becomes the same key.
+	// it won't equal the float64 literal, float32(0.1) is stored as 0.10000000149011612.
+	case reflect.Float32, reflect.Float64:
+		return js.ValueOf(v.Float()), nil
+
+	// nil pointers and interfaces are stored as null.
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return js.Null(), nil
+		}
+
+		return marshal(v.Elem())
+
+	case reflect.Slice:
+		return marshalSlice(v)
+
+	case reflect.Array:
+		return marshalArray(v)
+
+	case reflect.Map:
+		return marshalMap(v)
+
+	case reflect.Struct:
+		return marshalStruct(v)
+
+	// indexeddb would throw a `DataCloneError`, as the structured clone algorithm can't copy these.
+	case reflect.Func, reflect.Chan, reflect.Complex64, reflect.Complex128:
+		return js.Value{}, errors.Join(ErrInvalidType, fmt.Errorf("%s values cannot be stored, type: %s", v.Kind(), v.Type()))
+
+	default:
+		return js.Value{}, errors.Join(ErrInvalidType, fmt.Errorf("type: %s", v.Type()))
+	}
+}
+
+func marshalSlice(v reflect.Value) (js.Value, error) {
+	// a nil slice is stored as null, like JSON.
+	if v.IsNil() {
+		return js.Null(), nil
+	}
+
+	// copy bytes into a typed array, which indexeddb stores as binary.
+	if v.Type().Elem().Kind() == reflect.Uint8 {
+		arr := Uint8Array.New(v.Len())
+		js.CopyBytesToJS(arr, v.Bytes())
+
+		return arr, nil
+	}
+
+	return marshalArray(v)
+}
+
+// convert a slice or array to a javascript array, recursing into the elements.
+func marshalArray(v reflect.Value) (js.Value, error) {
+	arr := Array.New()
+
+	for i := 0; i < v.Len(); i++ {
+		el, err := marshal(v.Index(i))
+		if err != nil {
+			return js.Value{}, fmt.Errorf("index %d: %w", i, err)
+		}
+
+		arr.Call("push", el)
+	}
+
+	return arr, nil
+}
+
+func marshalMap(v reflect.Value) (js.Value, error) {
+	// a nil map is stored as null, like JSON.
+	if v.IsNil() {
+		return js.Null(), nil
+	}
+
+	obj := Object.New()
+
+	iter := v.MapRange()
+
+	for iter.Next() {
+		key, err := mapKey(iter.Key())
+		if err != nil {
+			return js.Value{}, err
+		}
+
+		val, err := marshal(iter.Value())
+		if err != nil {
+			return js.Value{}, fmt.Errorf("key %s: %w", key, err)
+		}
+
+		obj.Set(key, val)
+	}
+
+	return obj, nil
+}
+
+// convert a map key to an object property, like JSON only strings and integers are allowed.
+func mapKey(k reflect.Value) (string, error) {
+	switch k.Kind() {
+	case reflect.String:
+		return k.String(), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+
+	default:
+		return "", errors.Join(ErrInvalidType, fmt.Errorf("map key type: %s", k.Type()))
+	}
+}
+
+func marshalStruct(v reflect.Value) (js.Value, error) {
+	obj := Object.New()
+
+	for _, f := range fields(v.Type()) {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok {
+			continue
+		}
+
+		// skip empty values when asked to.
+		if f.omitEmpty && empty(fv) {
+			continue
+		}
+
+		val, err := marshal(fv)
+		if err != nil {
+			return js.Value{}, fmt.Errorf("field %s: %w", f.name, err)
+		}
+
+		obj.Set(f.name, val)
+	}
+
+	return obj, nil
+}
+
+// a struct field and the name it's stored under.
+type field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+
+	// tagged with `idb:"key"`.
+	key bool
+}
+
+// list the exported fields of a struct, flattening embedded structs like `encoding/json`.
+func fields(t reflect.Type) []field {
+	var fs []field
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+
+		// flatten untagged embedded structs.
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+
+			if ft.Kind() == reflect.Struct {
+				for _, f := range fields(ft) {
+					f.index = append([]int{i}, f.index...)
+					fs = append(fs, f)
+				}
+
+				continue
+			}
+		}
+
+		// skip unexported fields.
+		if !sf.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+
+		fs = append(fs, field{
+			name:      name,
+			index:     []int{i},
+			omitEmpty: strings.Contains(opts, "omitempty"),
+			key:       sf.Tag.Get("idb") == "key",
+		})
+	}
+
+	return fs
+}
+
+// find the field of a struct tagged with `idb:"key"`, reporting false if there isn't one.
+func structKey(x any) (reflect.Value, bool) {
+	v := reflect.ValueOf(x)
+
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return reflect.Value{}, false
+		}
+
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+
+	for _, f := range fields(v.Type()) {
+		if !f.key {
+			continue
+		}
+
+		return fieldByIndex(v, f.index)
+	}
+
+	return reflect.Value{}, false
+}
+
+// get a field by index, reporting false if it's behind a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+
+			v = v.Elem()
+		}
+
+		v = v.Field(x)
+	}
+
+	return v, true
+}
+
+// empty matches the `omitempty` semantics of `encoding/json`.
+func empty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+
+	case reflect.Struct:
+		return false
+
+	default:
+		return v.IsZero()
+	}
+}
+
+// populate a Go value from a javascript value, the inverse of `Marshal`.
+// dst must be a non-nil pointer, null and undefined values leave the destination as is.
+func Unmarshal(v js.Value, dst any) error {
+	rv := reflect.ValueOf(dst)
+
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("destination must be a non-nil pointer, got %T", dst)
+	}
+
+	return unmarshal(v, rv.Elem())
+}
+
+func unmarshal(v js.Value, rv reflect.Value) error {
+	// javascript values are set as is.
+	if rv.Type() == jsValueType {
+		rv.Set(reflect.ValueOf(v))
+		return nil
+	}
+
+	// null and undefined are treated as missing, like null in JSON.
+	if v.IsNull() || v.IsUndefined() {
+		return nil
+	}
+
+	if rv.Type() == timeType {
+		t, err := Time(v)
+		if err != nil {
+			return err
+		}
+
+		rv.Set(reflect.ValueOf(t))
+
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Pointer:
+		// allocate the pointer if needed.
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+
+		return unmarshal(v, rv.Elem())
+
+	case reflect.Interface:
+		// only an empty interface can hold an arbitrary value.
+		if rv.NumMethod() != 0 {
+			return mismatch(v, rv)
+		}
+
+		x, err := generic(v)
+		if err != nil {
+			return err
+		}
+
+		rv.Set(reflect.ValueOf(x))
+
+		return nil
+
+	case reflect.String:
+		if !is(v, js.TypeString) {
+			return mismatch(v, rv)
+		}
+
+		rv.SetString(v.String())
+
+	case reflect.Bool:
+		if !is(v, js.TypeBoolean) {
+			return mismatch(v, rv)
+		}
+
+		rv.SetBool(v.Bool())
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := Int64(v)
+		if err != nil {
+			return err
+		}
+
+		if rv.OverflowInt(n) {
+			return fmt.Errorf("integer %d overflows %s", n, rv.Type())
+		}
+
+		rv.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := Uint64(v)
+		if err != nil {
+			return err
+		}
+
+		if rv.OverflowUint(n) {
+			return fmt.Errorf("integer %d overflows %s", n, rv.Type())
+		}
+
+		rv.SetUint(n)
+
+	case reflect.Float32, reflect.Float64:
+		if !is(v, js.TypeNumber) {
+			return mismatch(v, rv)
+		}
+
+		rv.SetFloat(v.Float())
+
+	case reflect.Slice:
+		// copy a typed array or buffer back into bytes.
+		if rv.Type().Elem().Kind() == reflect.Uint8 && (v.InstanceOf(Uint8Array) || v.InstanceOf(ArrayBuffer)) {
+			b, err := Bytes(v)
+			if err != nil {
+				return err
+			}
+
+			rv.SetBytes(b)
+
+			return nil
+		}
+
+		if !Array.Call("isArray", v).Bool() {
+			return mismatch(v, rv)
+		}
+
+		s := reflect.MakeSlice(rv.Type(), v.Length(), v.Length())
+
+		for i := 0; i < v.Length(); i++ {
+			err := unmarshal(v.Index(i), s.Index(i))
+			if err != nil {
+				return fmt.Errorf("index %d: %w", i, err)
+			}
+		}
+
+		rv.Set(s)
+
+	case reflect.Array:
+		if !Array.Call("isArray", v).Bool() {
+			return mismatch(v, rv)
+		}
+
+		// like JSON, extra elements are dropped and missing elements are zeroed.
+		for i := 0; i < rv.Len(); i++ {
+			if i >= v.Length() {
+				rv.Index(i).SetZero()
+
+				continue
+			}
+
+			err := unmarshal(v.Index(i), rv.Index(i))
+			if err != nil {
+				return fmt.Errorf("index %d: %w", i, err)
+			}
+		}
+
+	case reflect.Map:
+		if !is(v, js.TypeObject) {
+			return mismatch(v, rv)
+		}
+
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMap(rv.Type()))
+		}
+
+		keys := Object.Call("keys", v)
+
+		for i := 0; i < keys.Length(); i++ {
+			prop := keys.Index(i).String()
+
+			key, err := parseMapKey(prop, rv.Type().Key())
+			if err != nil {
+				return err
+			}
+
+			el := reflect.New(rv.Type().Elem()).Elem()
+
+			err = unmarshal(v.Get(prop), el)
+			if err != nil {
+				return fmt.Errorf("key %s: %w", prop, err)
+			}
+
+			rv.SetMapIndex(key, el)
+		}
+
+	case reflect.Struct:
+		if !is(v, js.TypeObject) {
+			return mismatch(v, rv)
+		}
+
+		for _, f := range fields(rv.Type()) {
+			prop := v.Get(f.name)
+
+			// leave missing fields as is.
+			if prop.IsUndefined() {
+				continue
+			}
+
+			err := unmarshal(prop, allocFieldByIndex(rv, f.index))
+			if err != nil {
+				return fmt.Errorf("field %s: %w", f.name, err)
+			}
+		}
+
+	default:
+		return mismatch(v, rv)
+	}
+
+	return nil
+}
+
+// convert a javascript date to a time.
+func Time(v js.Value) (time.Time, error) {
+	if !v.InstanceOf(Date) {
+		return time.Time{}, errors.Join(ErrInvalidType, fmt.Errorf("javascript %s is not a date", typeName(v)))
+	}
+
+	ms := v.Call("getTime").Float()
+
+	// an invalid date has a time of NaN.
+	if math.IsNaN(ms) {
+		return time.Time{}, errors.New("invalid date")
+	}
+
+	return time.UnixMilli(int64(ms)), nil
+}
+
+// copy a `Uint8Array` or `ArrayBuffer` to bytes, such as a stored `[]byte`.
+func Bytes(v js.Value) ([]byte, error) {
+	// view the buffer as bytes so it can be copied.
+	if v.InstanceOf(ArrayBuffer) {
+		v = Uint8Array.New(v)
+	}
+
+	if !v.InstanceOf(Uint8Array) {
+		return nil, errors.Join(ErrInvalidType, fmt.Errorf("javascript %s is not a Uint8Array or ArrayBuffer", typeName(v)))
+	}
+
+	b := make([]byte, v.Length())
+	js.CopyBytesToGo(b, v)
+
+	return b, nil
+}
+
+// convert an object property back to a map key, the inverse of `mapKey`.
+func parseMapKey(prop string, t reflect.Type) (reflect.Value, error) {
+	key := reflect.New(t).Elem()
+
+	switch t.Kind() {
+	case reflect.String:
+		key.SetString(prop)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(prop, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		key.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(prop, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		key.SetUint(n)
+
+	default:
+		return reflect.Value{}, errors.Join(ErrInvalidType, fmt.Errorf("map key type: %s", t))
+	}
+
+	return key, nil
+}
+
+// get a field by index, allocating nil embedded pointers along the way.
+func allocFieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+
+			v = v.Elem()
+		}
+
+		v = v.Field(x)
+	}
+
+	return v
+}
+
+// convert a javascript value to the Go type `encoding/json` would use for an empty interface.
+func generic(v js.Value) (any, error) {
+	// a bigint is the only way to store a large integer.
+	if isBigInt(v) {
+		n, err := Int64(v)
+		if err != nil {
+			return Uint64(v)
+		}
+
+		return n, nil
+	}
+
+	switch v.Type() {
+	case js.TypeNull, js.TypeUndefined:
+		return nil, nil
+
+	case js.TypeString:
+		return v.String(), nil
+
+	case js.TypeBoolean:
+		return v.Bool(), nil
+
+	case js.TypeNumber:
+		return v.Float(), nil
+
+	case js.TypeObject:
+		if Array.Call("isArray", v).Bool() {
+			var s []any
+
+			err := unmarshal(v, reflect.ValueOf(&s).Elem())
+			if err != nil {
+				return nil, err
+			}
+
+			return s, nil
+		}
+
+		m := make(map[string]any)
+
+		keys := Object.Call("keys", v)
+
+		for i := 0; i < keys.Length(); i++ {
+			key := keys.Index(i).String()
+
+			x, err := generic(v.Get(key))
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %w", key, err)
+			}
+
+			m[key] = x
+		}
+
+		return m, nil
+
+	default:
+		return nil, errors.Join(ErrInvalidType, fmt.Errorf("javascript type: %s", v.Type()))
+	}
+}
+
+// convert a javascript number or bigint to an int64 without losing precision.
+func Int64(v js.Value) (int64, error) {
+	if isBigInt(v) {
+		return strconv.ParseInt(jsString.Invoke(v).String(), 10, 64)
+	}
+
+	if v.Type() != js.TypeNumber {
+		return 0, errors.Join(ErrInvalidType, fmt.Errorf("javascript %s is not a number", v.Type()))
+	}
+
+	f := v.Float()
+	n := int64(f)
+
+	// ensure the number is whole.
+	if float64(n) != f {
+		return 0, fmt.Errorf("number %v is not an integer", f)
+	}
+
+	return n, nil
+}
+
+// convert a javascript number or bigint to a uint64 without losing precision.
+func Uint64(v js.Value) (uint64, error) {
+	if isBigInt(v) {
+		return strconv.ParseUint(jsString.Invoke(v).String(), 10, 64)
+	}
+
+	if v.Type() != js.TypeNumber {
+		return 0, errors.Join(ErrInvalidType, fmt.Errorf("javascript %s is not a number", v.Type()))
+	}
+
+	f := v.Float()
+	n := uint64(f)
+
+	// ensure the number is whole and positive.
+	if f < 0 || float64(n) != f {
+		return 0, fmt.Errorf("number %v is not an unsigned integer", f)
+	}
+
+	return n, nil
+}
+
+// check if a value is a bigint, `js.Value.Type` panics on a bigint so it must be checked first.
+func isBigInt(v js.Value) bool {
+	if v.IsNull() || v.IsUndefined() {
+		return false
+	}
+
+	// a bigint primitive is boxed by `Object` into an instance of `BigInt`.
+	return Object.Invoke(v).InstanceOf(BigInt)
+}
+
+// check the type of a value, safely handling a bigint.
+func is(v js.Value, t js.Type) bool {
+	return !isBigInt(v) && v.Type() == t
+}
+
+// the name of the type of a value, safely handling a bigint.
+func typeName(v js.Value) string {
+	if isBigInt(v) {
+		return "bigint"
+	}
+
+	return v.Type().String()
+}
+
+func mismatch(v js.Value, rv reflect.Value) error {
+	return errors.Join(ErrInvalidType, fmt.Errorf("cannot unmarshal javascript %s into %s", typeName(v), rv.Type()))
+}
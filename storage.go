@@ -0,0 +1,51 @@
+//go:build js && wasm
+
+package indexeddb
+
+import (
+	"errors"
+	"syscall/js"
+)
+
+// https://developer.mozilla.org/en-US/docs/Web/API/StorageManager.
+func storageManager() (js.Value, error) {
+	nav := js.Global().Get("navigator")
+
+	// not every browser or context has a storage manager.
+	if nav.IsUndefined() || nav.Get("storage").IsUndefined() {
+		return js.Value{}, errors.New("storage manager is not supported")
+	}
+
+	return nav.Get("storage"), nil
+}
+
+// estimate how many bytes are used and available to the origin, including indexeddb.
+func StorageEstimate() (usage, quota int64, err error) {
+	storage, err := storageManager()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	res, err := awaitPromise(storage.Call("estimate"))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return int64(res.Get("usage").Float()), int64(res.Get("quota").Float()), nil
+}
+
+// ask the browser not to evict the origin's data, including indexeddb, under storage pressure.
+// reports whether persistence was granted.
+func PersistStorage() (bool, error) {
+	storage, err := storageManager()
+	if err != nil {
+		return false, err
+	}
+
+	res, err := awaitPromise(storage.Call("persist"))
+	if err != nil {
+		return false, err
+	}
+
+	return res.Bool(), nil
+}
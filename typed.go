@@ -0,0 +1,93 @@
+//go:build js && wasm
+
+package indexeddb
+
+import "syscall/js"
+
+// a typed store wraps a store, converting values to and from `T` using `Codec`.
+// struct fields follow their `json` tag names and options, but `json.Marshaler` isn't used.
+// values used to be round-tripped through JSON, so times and byte slices written before are strings and won't decode.
+type TypedStore[T any] struct {
+	store *Store
+}
+
+func NewTypedStore[T any](s *Store) *TypedStore[T] {
+	return &TypedStore[T]{
+		store: s,
+	}
+}
+
+// get the value for the key, returning the zero value of `T` if it's not found.
+func (ts *TypedStore[T]) Get(key any) (T, error) {
+	return decode[T](ts.store.Get(key))
+}
+
+func (ts *TypedStore[T]) Put(key any, v T) error {
+	return ts.store.Put(key, v)
+}
+
+func (ts *TypedStore[T]) GetAll(query any, limit int) ([]T, error) {
+	res, err := ts.store.GetAll(query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	vs := make([]T, len(res))
+
+	for i := range res {
+		vs[i], err = decode[T](&res[i], nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return vs, nil
+}
+
+// get the value for the key and decode it into `T`, returning the zero value on error.
+func getAs[T any](s *Store, key any) (T, error) {
+	return decode[T](s.Get(key))
+}
+
+// decode the result of a get into `T`, returning the zero value on error.
+func decode[T any](res *js.Value, err error) (T, error) {
+	var v T
+
+	if err != nil {
+		return v, err
+	}
+
+	err = Codec.Unmarshal(*res, &v)
+	if err != nil {
+		var zero T
+
+		return zero, err
+	}
+
+	return v, nil
+}
+
+// get the first record for the index key and decode it into `T`, returning the zero value on error.
+func GetFromIndex[T any](idx *Index, key any) (T, error) {
+	return decode[T](idx.Get(key))
+}
+
+// get the value for the key as an int.
+func GetInt(s *Store, key any) (int, error) {
+	return getAs[int](s, key)
+}
+
+// get the value for the key as a string.
+func GetString(s *Store, key any) (string, error) {
+	return getAs[string](s, key)
+}
+
+// get the value for the key as a bool.
+func GetBool(s *Store, key any) (bool, error) {
+	return getAs[bool](s, key)
+}
+
+// get the value for the key as a float64.
+func GetFloat(s *Store, key any) (float64, error) {
+	return getAs[float64](s, key)
+}